@@ -2,21 +2,36 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/allegro/bigcache/v3"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/config"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/cache"
 	strg "github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/flat"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/house"
+	moderationHandlers "github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/moderation"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/preferences"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/savedsearch"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/webhook"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/moderation"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/notifications"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/notify"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/templates"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/webhooks"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/blob"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/cachestore"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/db"
 	mwLogger "github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/middleware"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/sender"
 	"github.com/go-chi/render"
+	"github.com/redis/go-redis/v9"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -32,7 +47,7 @@ func main() {
 	log := setupLogger()
 	log.Info("initializing server", slog.String("address", cfg.Address))
 	log.Debug("logger debug mode enabled")
-	log.Info("cfg ", cfg)
+	log.Info("cfg", slog.Any("cfg", cfg))
 
 	// database
 	database, err := db.NewDB(ctx)
@@ -42,19 +57,106 @@ func main() {
 	defer database.GetPool(ctx).Close()
 
 	//storage
-	bigcache, err := bigcache.New(ctx, bigcache.DefaultConfig(10*time.Minute))
+	cacheStore, err := setupCacheStore(ctx, cfg.Cache)
 	if err != nil {
-		log.Error(err.Error())
+		log.Error("failed to init cache", slog.Any("error", err))
+		os.Exit(1)
 	}
 
-	storage := cache.NewClient(log, bigcache, strg.New(database, log))
-	_ = storage
+	storage := cache.NewClient(log, cacheStore, strg.New(database, log), cfg.CacheSoftTTL)
+	switch cfg.CacheInvalidation {
+	case "local":
+	case "postgres":
+		storage.ShareInvalidations(database, cfg.CacheFallbackTTL)
+		go storage.Run(ctx)
+	default:
+		log.Error("unknown cache invalidation", slog.String("invalidation", cfg.CacheInvalidation))
+		os.Exit(1)
+	}
 
 	if err != nil {
 		log.Error("failed to init storage ")
 		os.Exit(1)
 	}
 
+	rules, err := moderation.Load(cfg.Moderation.RulesPath, storage, log)
+	if err != nil {
+		log.Error("failed to load moderation rules", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	blobStore, err := setupBlobStore(cfg.BlobStorage)
+	if err != nil {
+		log.Error("failed to init blob storage", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	renderer, err := templates.New()
+	if err != nil {
+		log.Error("failed to parse email templates", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	tokens := notify.NewTokens(cfg.Notify.UnsubscribeSecret, cfg.Notify.PublicURL)
+	emails := notifications.New(
+		log, storage, sender.New(), notifications.Options{
+			Workers:      cfg.Notify.EmailWorkers,
+			MaxAttempts:  cfg.Notify.EmailMaxAttempts,
+			BaseBackoff:  cfg.Notify.EmailBaseBackoff,
+			MaxBackoff:   cfg.Notify.EmailMaxBackoff,
+			SendTimeout:  cfg.Notify.EmailSendTimeout,
+			PollInterval: cfg.Notify.EmailPollInterval,
+			Retention:    cfg.Notify.EmailRetention,
+		},
+	)
+	go emails.Run(ctx)
+	dispatcher := notify.NewDispatcher(log, storage, emails, tokens, renderer)
+	notifier := notify.New(ctx, log, storage, dispatcher)
+	matcher := notify.NewMatcher(log, storage, dispatcher, cfg.Notify.SearchAlertsInterval)
+	go matcher.Run(ctx)
+	go notify.NewDigest(log, storage, dispatcher, cfg.Notify.DailyDigestHour).Run(ctx)
+
+	hooks := webhooks.New(
+		log, storage, webhooks.Options{
+			MaxAttempts:  cfg.Webhooks.MaxAttempts,
+			BaseBackoff:  cfg.Webhooks.BaseBackoff,
+			MaxBackoff:   cfg.Webhooks.MaxBackoff,
+			Timeout:      cfg.Webhooks.DeliveryTimeout,
+			PollInterval: cfg.Webhooks.PollInterval,
+			BatchSize:    cfg.Webhooks.BatchSize,
+		},
+	)
+	go hooks.Run(ctx)
+
+	hub := events.NewHub()
+	bus := events.NewBus()
+	bus.Subscribe(hub.Publish)
+	bus.Subscribe(notifier.Handle)
+	bus.Subscribe(
+		func(event events.Event) {
+			matcher.Handle(ctx, event)
+		},
+	)
+	bus.Subscribe(
+		func(event events.Event) {
+			hooks.Handle(ctx, event)
+		},
+	)
+	switch cfg.EventsBackend {
+	case "local":
+	case "postgres":
+		relay := events.NewRelay(log, database, hub)
+		go relay.Run(ctx)
+		bus.Subscribe(
+			func(event events.Event) {
+				relay.Publish(ctx, event)
+			},
+		)
+	default:
+		log.Error("unknown events backend", slog.String("backend", cfg.EventsBackend))
+		os.Exit(1)
+	}
+
 	//router
 	router := chi.NewRouter()
 
@@ -70,6 +172,9 @@ func main() {
 			r.Get("/dummyLogin", auth.GetDummyLogin(log))
 			r.Post("/register", auth.Register(ctx, log, storage))
 			r.Post("/login", auth.Login(ctx, log, storage))
+			r.Get("/photos/*", flat.GetPhoto(log, blobStore))
+			r.Get("/unsubscribe", preferences.ConfirmUnsubscribe(log, tokens))
+			r.Post("/unsubscribe", preferences.Unsubscribe(ctx, log, storage, tokens))
 		},
 	)
 
@@ -77,16 +182,42 @@ func main() {
 		func(r chi.Router) {
 			r.Use(mwLogger.JWTValidateMW(log))
 
-			r.Post("/flat/create", flat.Create(ctx, log, storage))
+			r.Post("/flat/create", flat.Create(ctx, log, storage, rules, bus))
+			r.Post("/flat/edit", flat.Edit(ctx, log, storage, rules, bus))
+			r.Post("/flat/{id}/availability", flat.SetAvailability(ctx, log, storage))
+			r.Get("/flat/{id}/price-history", flat.PriceHistory(ctx, log, storage))
+			r.Post("/flat/{id}/favorite", flat.AddFavorite(ctx, log, storage))
+			r.Delete("/flat/{id}/favorite", flat.RemoveFavorite(ctx, log, storage))
+			r.Get("/me/favorites", flat.Favorites(ctx, log, storage))
+			r.Post("/me/searches", savedsearch.Create(ctx, log, storage))
+			r.Get("/me/searches", savedsearch.List(ctx, log, storage))
+			r.Delete("/me/searches/{id}", savedsearch.Delete(ctx, log, storage))
+			r.Get("/me/notifications", preferences.Get(ctx, log, storage))
+			r.Put("/me/notifications", preferences.Update(ctx, log, storage))
+			r.Post("/flat/{id}/photos", flat.UploadPhotos(ctx, log, storage, blobStore, bus))
 			r.Get("/house/{id}", house.GetList(ctx, log, storage))
+			r.Get("/house/{id}/events", house.Events(log, hub))
+			r.Get("/houses", house.GetHouses(ctx, log, storage))
+			r.Get("/houses/suggest", house.Suggest(ctx, log, storage))
+			r.Get("/houses/nearby", house.Nearby(ctx, log, storage))
+			r.Get("/houses/in-bbox", house.InBox(ctx, log, storage))
+			r.Get("/flats/search", flat.Search(ctx, log, storage))
 
 			r.Group(
 				func(c chi.Router) {
 					c.Use(mwLogger.JWTValidateModeratorMW(log))
 
 					c.Post("/house/create", house.Create(ctx, log, storage))
-					c.Post("/flat/update", flat.Moderate(ctx, log, storage))
-					//c.Post("/house/get", house.Get(ctx, log, storage))
+					c.Post("/flat/update", flat.Moderate(ctx, log, storage, bus))
+					c.Get("/moderation/rules", moderationHandlers.Rules(log, rules))
+					c.Get("/moderation/duplicates", moderationHandlers.Duplicates(ctx, log, storage))
+					c.Get("/moderation/ws", moderationHandlers.Queue(ctx, log, storage, hub, bus))
+					c.Get("/moderation/notifications", moderationHandlers.Notifications(ctx, log, emails))
+					c.Get("/moderation/emails/{name}/preview", moderationHandlers.PreviewEmail(log, renderer))
+					c.Post("/webhooks", webhook.Create(ctx, log, storage))
+					c.Get("/webhooks", webhook.List(ctx, log, storage))
+					c.Delete("/webhooks/{id}", webhook.Delete(ctx, log, storage))
+					c.Get("/webhooks/{id}/deliveries", webhook.Deliveries(ctx, log, storage))
 				},
 			)
 		},
@@ -95,7 +226,7 @@ func main() {
 	// long time request change
 	serv := &http.Server{
 		Addr:         cfg.Address,
-		Handler:      http.TimeoutHandler(router, 1*time.Second, "long time request"),
+		Handler:      withTimeout(router, 1*time.Second, "/photos", "/events", "/ws"),
 		ReadTimeout:  cfg.Timeout,
 		WriteTimeout: cfg.Timeout,
 		IdleTimeout:  cfg.IdleTimeout,
@@ -108,6 +239,55 @@ func main() {
 	}
 }
 
+// withTimeout limits the request time except for paths with the long suffixes
+// such as uploads, which manage their deadlines themselves.
+func withTimeout(handler http.Handler, timeout time.Duration, long ...string) http.Handler {
+	limited := http.TimeoutHandler(handler, timeout, "long time request")
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			for _, suffix := range long {
+				if strings.HasSuffix(r.URL.Path, suffix) {
+					handler.ServeHTTP(w, r)
+					return
+				}
+			}
+			limited.ServeHTTP(w, r)
+		},
+	)
+}
+
+func setupBlobStore(cfg config.BlobStorage) (blob.Store, error) {
+	switch cfg.Backend {
+	case "local":
+		return blob.NewLocal(cfg.Dir, cfg.PublicURL)
+	case "s3":
+		return blob.NewS3(cfg.S3Endpoint, cfg.S3Bucket, cfg.S3Region, cfg.S3AccessKey, cfg.S3SecretKey, ""), nil
+	default:
+		return nil, fmt.Errorf("unknown blob backend %q", cfg.Backend)
+	}
+}
+
+func setupCacheStore(ctx context.Context, cfg config.Cache) (cachestore.Store, error) {
+	switch cfg.CacheBackend {
+	case "bigcache":
+		return cachestore.NewBigcache(ctx, cfg.CacheTTL)
+	case "lru":
+		return cachestore.NewLRU(cfg.CacheLRUSize, cfg.CacheTTL), nil
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddress,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		})
+		if err := client.Ping(ctx).Err(); err != nil {
+			return nil, fmt.Errorf("connect to redis: %w", err)
+		}
+		return cachestore.NewRedis(client, "flats:", cfg.CacheTTL), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.CacheBackend)
+	}
+}
+
 // env string
 func setupLogger() *slog.Logger {
 	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
@@ -1,8 +1,8 @@
 package config
 
 import (
-	"fmt"
 	"log"
+	"log/slog"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
@@ -12,6 +12,12 @@ type Config struct {
 	Env          string `yaml:"env" env-default:"local"`
 	HTTPServer   `yaml:"http_server"`
 	DatabaseData `yaml:"database_data"`
+	BlobStorage  `yaml:"blob_storage"`
+	Moderation   `yaml:"moderation"`
+	Notify       `yaml:"notify"`
+	Webhooks     `yaml:"webhooks"`
+	Events       `yaml:"events"`
+	Cache        `yaml:"cache"`
 }
 
 type HTTPServer struct {
@@ -31,12 +37,105 @@ type DatabaseData struct {
 	DBName   string `yaml:"dbname" env:"POSTGRES_DB" env-default:"postgres"`
 }
 
+type BlobStorage struct {
+	Backend     string `yaml:"backend" env:"BLOB_BACKEND" env-default:"local"`
+	Dir         string `yaml:"dir" env:"BLOB_DIR" env-default:"./uploads"`
+	PublicURL   string `yaml:"public_url" env:"BLOB_PUBLIC_URL" env-default:"/photos"`
+	S3Endpoint  string `yaml:"s3_endpoint" env:"S3_ENDPOINT"`
+	S3Bucket    string `yaml:"s3_bucket" env:"S3_BUCKET"`
+	S3Region    string `yaml:"s3_region" env:"S3_REGION" env-default:"us-east-1"`
+	S3AccessKey string `yaml:"s3_access_key" env:"S3_ACCESS_KEY"`
+	S3SecretKey string `yaml:"s3_secret_key" env:"S3_SECRET_KEY"`
+}
+
+type Moderation struct {
+	RulesPath string `yaml:"rules_path" env:"MODERATION_RULES_PATH" env-default:"./config/moderation_rules.yaml"`
+}
+
+type Notify struct {
+	// SearchAlertsInterval is how long approved flats are collected before
+	// the saved search alerts are sent
+	SearchAlertsInterval time.Duration `yaml:"search_alerts_interval" env:"SEARCH_ALERTS_INTERVAL" env-default:"1m"`
+	// PublicURL is the address of the service used in the email links
+	PublicURL string `yaml:"public_url" env:"PUBLIC_URL" env-default:"http://localhost:8082"`
+	// UnsubscribeSecret signs the unsubscribe links, it has no default so
+	// the links cannot be forged with a well-known key
+	UnsubscribeSecret string `yaml:"unsubscribe_secret" env:"UNSUBSCRIBE_SECRET" env-required:"true"`
+	// DailyDigestHour is the UTC hour the daily digests are sent at
+	DailyDigestHour  int           `yaml:"daily_digest_hour" env:"DAILY_DIGEST_HOUR" env-default:"9"`
+	EmailWorkers     int           `yaml:"email_workers" env:"EMAIL_WORKERS" env-default:"8"`
+	EmailMaxAttempts int           `yaml:"email_max_attempts" env:"EMAIL_MAX_ATTEMPTS" env-default:"6"`
+	EmailBaseBackoff time.Duration `yaml:"email_base_backoff" env:"EMAIL_BASE_BACKOFF" env-default:"5s"`
+	EmailMaxBackoff  time.Duration `yaml:"email_max_backoff" env:"EMAIL_MAX_BACKOFF" env-default:"10m"`
+	// EmailSendTimeout bounds a single send, the sender takes up to 3s
+	EmailSendTimeout  time.Duration `yaml:"email_send_timeout" env:"EMAIL_SEND_TIMEOUT" env-default:"10s"`
+	EmailPollInterval time.Duration `yaml:"email_poll_interval" env:"EMAIL_POLL_INTERVAL" env-default:"1s"`
+	// EmailRetention is how long the sent emails are kept, a message with
+	// the key of a removed one is sent again
+	EmailRetention time.Duration `yaml:"email_retention" env:"EMAIL_RETENTION" env-default:"168h"`
+}
+
+type Webhooks struct {
+	MaxAttempts int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" env-default:"8"`
+	BaseBackoff time.Duration `yaml:"base_backoff" env:"WEBHOOK_BASE_BACKOFF" env-default:"10s"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" env:"WEBHOOK_MAX_BACKOFF" env-default:"1h"`
+	// DeliveryTimeout bounds a single delivery request
+	DeliveryTimeout time.Duration `yaml:"delivery_timeout" env:"WEBHOOK_TIMEOUT" env-default:"5s"`
+	PollInterval    time.Duration `yaml:"poll_interval" env:"WEBHOOK_POLL_INTERVAL" env-default:"2s"`
+	BatchSize       int           `yaml:"batch_size" env:"WEBHOOK_BATCH_SIZE" env-default:"20"`
+}
+
+type Events struct {
+	// EventsBackend is "local" for a single replica or "postgres" to share
+	// the events between replicas with LISTEN/NOTIFY
+	EventsBackend string `yaml:"backend" env:"EVENTS_BACKEND" env-default:"local"`
+}
+
+type Cache struct {
+	// CacheBackend is "bigcache" or "lru" for a cache per replica or "redis"
+	// for a cache shared by the replicas
+	CacheBackend string        `yaml:"backend" env:"CACHE_BACKEND" env-default:"bigcache"`
+	CacheTTL     time.Duration `yaml:"ttl" env:"CACHE_TTL" env-default:"10m"`
+	// CacheSoftTTL is the age after which a cached list is served while it
+	// is refreshed in the background
+	CacheSoftTTL time.Duration `yaml:"soft_ttl" env:"CACHE_SOFT_TTL" env-default:"1m"`
+	// CacheLRUSize is the number of entries kept by the lru backend
+	CacheLRUSize  int    `yaml:"lru_size" env:"CACHE_LRU_SIZE" env-default:"10000"`
+	RedisAddress  string `yaml:"redis_address" env:"REDIS_ADDRESS" env-default:"localhost:6379"`
+	RedisPassword string `yaml:"redis_password" env:"REDIS_PASSWORD"`
+	RedisDB       int    `yaml:"redis_db" env:"REDIS_DB" env-default:"0"`
+	// CacheInvalidation is "local" for a single replica or "postgres" to
+	// evict the lists invalidated by other replicas with LISTEN/NOTIFY
+	CacheInvalidation string `yaml:"invalidation" env:"CACHE_INVALIDATION" env-default:"local"`
+	// CacheFallbackTTL limits the age of the cached lists while the
+	// invalidations of other replicas cannot be received
+	CacheFallbackTTL time.Duration `yaml:"fallback_ttl" env:"CACHE_FALLBACK_TTL" env-default:"15s"`
+}
+
 func MustLoad() *Config {
 	var cfg Config
 	err := cleanenv.ReadEnv(&cfg)
-	fmt.Println(cfg)
 	if err != nil {
 		log.Fatalf("cannot read config: %s", err)
 	}
+	if cfg.UnsubscribeSecret == "" {
+		log.Fatalf("cannot read config: UNSUBSCRIBE_SECRET is empty")
+	}
+	if cfg.DailyDigestHour < 0 || cfg.DailyDigestHour > 23 {
+		log.Fatalf("cannot read config: DAILY_DIGEST_HOUR must be in [0, 23]")
+	}
 	return &cfg
 }
+
+// loggedConfig has the fields of Config but not its LogValue method.
+type loggedConfig Config
+
+// LogValue hides the secrets when the config is logged.
+func (c Config) LogValue() slog.Value {
+	for _, secret := range []*string{&c.Password, &c.S3SecretKey, &c.UnsubscribeSecret, &c.RedisPassword} {
+		if *secret != "" {
+			*secret = "[REDACTED]"
+		}
+	}
+	return slog.AnyValue(loggedConfig(c))
+}
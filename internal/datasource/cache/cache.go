@@ -2,17 +2,19 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
-	"github.com/allegro/bigcache/v3"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
-	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/house"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/cachestore"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/geohash"
 	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
@@ -20,14 +22,50 @@ const (
 	clientAll    = "client:all"
 )
 
+// defaultPageRows is the row count the house handler requests for the
+// first page: the page size plus one row to detect the next page.
+const defaultPageRows = services.DefaultLimit + 1
+
 type Client struct {
-	source datasource.Datasource
-	conn   *bigcache.BigCache
-	log    *slog.Logger
+	source      datasource.Datasource
+	conn        cachestore.Store
+	log         *slog.Logger
+	replication *replication
+	// softTTL is the age after which a cached list is refreshed in the
+	// background, zero disables the refresh
+	softTTL time.Duration
+	flight  *singleflight.Group
+	epochs  *epochs
+}
+
+// epochs counts the invalidations of every key. The counters are never
+// removed, a removed counter would start over and match an old epoch.
+type epochs struct {
+	mu   sync.Mutex
+	keys map[string]uint64
+}
+
+func (e *epochs) get(key string) uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.keys[key]
 }
 
-func NewClient(log *slog.Logger, conn *bigcache.BigCache, source datasource.Datasource) *Client {
-	return &Client{source: source, conn: conn, log: log}
+func (e *epochs) bump(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.keys[key]++
+}
+
+func NewClient(log *slog.Logger, conn cachestore.Store, source datasource.Datasource, softTTL time.Duration) *Client {
+	return &Client{
+		source:  source,
+		conn:    conn,
+		log:     log,
+		softTTL: softTTL,
+		flight:  &singleflight.Group{},
+		epochs:  &epochs{keys: make(map[string]uint64)},
+	}
 }
 
 func (c Client) SaveUser(ctx context.Context, email, password, userType string) (uuid.UUID, error) {
@@ -48,26 +86,58 @@ func (c Client) GetUserById(ctx context.Context, id uuid.UUID) (*structures.User
 	return result, nil
 }
 
-func (c Client) SaveHouse(ctx context.Context, address, developer string, year int) (*structures.House, error) {
+func (c Client) SaveHouse(ctx context.Context, house structures.House) (*structures.House, error) {
 	var err error
-	result, err := c.source.SaveHouse(ctx, address, developer, year)
+	result, err := c.source.SaveHouse(ctx, house)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = c.conn.Delete(fmt.Sprintf("%s:%d", clientAll, result.Id)); err != nil {
-		c.log.Error("failed to delete list of flats from cache (client)", err)
+	c.invalidateHouse(ctx, result.Id)
+
+	return result, nil
+}
+
+func (c Client) GetHouse(ctx context.Context, id int) (*structures.House, error) {
+	result, err := c.source.GetHouse(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+	return result, nil
+}
+
+func (c Client) GetHouses(ctx context.Context, filter structures.HouseFilter) (*[]structures.House, error) {
+	result, err := c.source.GetHouses(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
-	if err = c.conn.Delete(fmt.Sprintf("%s:%d", moderatorAll, result.Id)); err != nil {
-		c.log.Error("failed to delete list of flats from cache (moderator)", err)
+func (c Client) SuggestAddresses(
+	ctx context.Context, text string, limit int,
+) (*[]structures.AddressSuggestion, error) {
+	result, err := c.source.SuggestAddresses(ctx, text, limit)
+	if err != nil {
+		return nil, err
 	}
+	return result, nil
+}
 
+func (c Client) GetHousesNearby(
+	ctx context.Context, lat, lon, radius float64, cells []string, limit int,
+) (*[]structures.HouseDistance, error) {
+	result, err := c.source.GetHousesNearby(ctx, lat, lon, radius, cells, limit)
+	if err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
-func (c Client) GetHouse(ctx context.Context, id int) (*structures.House, error) {
-	result, err := c.source.GetHouse(ctx, id)
+func (c Client) GetHousesInBox(
+	ctx context.Context, box geohash.Box, cells []string, limit int,
+) (*[]structures.House, error) {
+	result, err := c.source.GetHousesInBox(ctx, box, cells, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -81,32 +151,38 @@ func (c Client) UpdateDate(ctx context.Context, time time.Time, id int) error {
 		return err
 	}
 
-	if err = c.conn.Delete(fmt.Sprintf("%s:%d", clientAll, id)); err != nil {
-		c.log.Error("failed to delete list of flats from cache (client)", err)
-	}
-
-	if err = c.conn.Delete(fmt.Sprintf("%s:%d", moderatorAll, id)); err != nil {
-		c.log.Error("failed to delete list of flats from cache (moderator)", err)
-	}
+	c.invalidateHouse(ctx, id)
 	return nil
 }
 
-func (c Client) SaveFlat(ctx context.Context, houseId, price, rooms int) (*structures.Flat, error) {
+func (c Client) SaveFlat(ctx context.Context, newFlat structures.Flat) (*structures.Flat, error) {
 	var err error
-	flat, err := c.source.SaveFlat(ctx, houseId, price, rooms)
+	flat, err := c.source.SaveFlat(ctx, newFlat)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = c.conn.Delete(fmt.Sprintf("%s:%d", clientAll, houseId)); err != nil {
-		c.log.Error("failed to delete list of flats from cache (client)", err)
+	c.invalidateHouse(ctx, flat.HouseId)
+
+	return flat, nil
+}
+
+func (c Client) SaveFlatPhoto(ctx context.Context, photo structures.Photo) (*structures.Photo, error) {
+	var err error
+	result, err := c.source.SaveFlatPhoto(ctx, photo)
+	if err != nil {
+		return nil, err
 	}
 
-	if err = c.conn.Delete(fmt.Sprintf("%s:%d", moderatorAll, houseId)); err != nil {
-		c.log.Error("failed to delete list of flats from cache (moderator)", err)
+	flat, err := c.source.GetFlat(ctx, photo.FlatId)
+	if err != nil {
+		c.log.Error("failed to find flat of the photo", slog.Any("error", err))
+		return result, nil
 	}
 
-	return flat, nil
+	c.invalidateHouse(ctx, flat.HouseId)
+
+	return result, nil
 }
 
 func (c Client) GetFlat(ctx context.Context, id int) (*structures.Flat, error) {
@@ -117,106 +193,446 @@ func (c Client) GetFlat(ctx context.Context, id int) (*structures.Flat, error) {
 	return flat, nil
 }
 
-func (c Client) UpdateStatus(ctx context.Context, id int, status string) error {
-	var err error
-	err = c.source.UpdateStatus(ctx, id, status)
+func (c Client) SearchFlats(
+	ctx context.Context, filter structures.FlatSearchFilter,
+) (*[]structures.FlatSearchResult, error) {
+	result, err := c.source.SearchFlats(ctx, filter)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return result, nil
+}
+
+func (c Client) EstimateSearchFlats(ctx context.Context, filter structures.FlatSearchFilter) (int64, error) {
+	return c.source.EstimateSearchFlats(ctx, filter)
+}
 
-	if err = c.conn.Delete(fmt.Sprintf("%s:%d", clientAll, id)); err != nil {
-		c.log.Error("failed to delete list of flats from cache (client)", err)
+func (c Client) UpdateStatus(
+	ctx context.Context, id int, status string, moderatorId uuid.UUID,
+) (*structures.Flat, error) {
+	flat, err := c.source.UpdateStatus(ctx, id, status, moderatorId)
+	if err != nil {
+		return nil, err
 	}
 
-	if err = c.conn.Delete(fmt.Sprintf("%s:%d", moderatorAll, id)); err != nil {
-		c.log.Error("failed to delete list of flats from cache (moderator)", err)
+	c.invalidateHouse(ctx, flat.HouseId)
+	return flat, nil
+}
+
+func (c Client) UpdateFlat(ctx context.Context, flat structures.Flat) (*structures.Flat, error) {
+	var err error
+	result, err := c.source.UpdateFlat(ctx, flat)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	c.invalidateHouse(ctx, result.HouseId)
+	return result, nil
 }
 
-func (c Client) GetListByClient(ctx context.Context, id int) (*[]structures.Flat, error) {
-	c.log.Info("cache start")
+func (c Client) UpdateAvailability(ctx context.Context, id int, from, to string) (*structures.Flat, error) {
 	var err error
-	data, err := c.conn.Get(fmt.Sprintf("%s:%d", clientAll, id))
-	if errors.Is(err, bigcache.ErrEntryNotFound) {
-		var err error
-		list, err := c.source.GetListByClient(ctx, id)
-		if err != nil {
-			c.log.Error("failed to cache list flats client")
-			return nil, err
-		}
+	result, err := c.source.UpdateAvailability(ctx, id, from, to)
+	if err != nil {
+		return nil, err
+	}
 
-		flats := house.GetListResponse{Flats: list}
+	c.invalidateHouse(ctx, result.HouseId)
+	return result, nil
+}
 
-		resp, err := json.Marshal(flats)
+func (c Client) GetPriceHistory(ctx context.Context, flatId int) (*[]structures.PriceChange, error) {
+	result, err := c.source.GetPriceHistory(ctx, flatId)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
-		if err != nil {
-			c.log.Error("failed to cache list flats client")
-			return nil, err
-		}
+func (c Client) AddFavorite(ctx context.Context, userId uuid.UUID, flatId int) error {
+	return c.source.AddFavorite(ctx, userId, flatId)
+}
 
-		if err := c.conn.Set(fmt.Sprintf("%s:%d", clientAll, id), resp); err != nil {
-			c.log.Error("failed to cache list flats client")
-			return nil, err
-		}
-		c.log.Info("cache end")
-		return list, nil
+func (c Client) RemoveFavorite(ctx context.Context, userId uuid.UUID, flatId int) error {
+	return c.source.RemoveFavorite(ctx, userId, flatId)
+}
+
+func (c Client) GetFavorites(ctx context.Context, userId uuid.UUID) (*[]structures.Flat, error) {
+	result, err := c.source.GetFavorites(ctx, userId)
+	if err != nil {
+		return nil, err
 	}
+	return result, nil
+}
+
+func (c Client) ClaimPriceChangeRecipients(
+	ctx context.Context, flatId, price int,
+) (*[]structures.PriceChangeRecipient, error) {
+	result, err := c.source.ClaimPriceChangeRecipients(ctx, flatId, price)
 	if err != nil {
-		c.log.Error("failed to cache list flats client")
 		return nil, err
 	}
+	return result, nil
+}
 
-	var result house.GetListResponse
-	err = json.Unmarshal(data, &result)
+func (c Client) SaveSearch(ctx context.Context, search structures.SavedSearch) (*structures.SavedSearch, error) {
+	result, err := c.source.SaveSearch(ctx, search)
 	if err != nil {
-		c.log.Error("failed to cache list flats client")
 		return nil, err
 	}
-	c.log.Info("cache end")
+	return result, nil
+}
 
-	return result.Flats, nil
+func (c Client) GetSavedSearches(ctx context.Context, userId uuid.UUID) (*[]structures.SavedSearch, error) {
+	result, err := c.source.GetSavedSearches(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-func (c Client) GetListByModerator(ctx context.Context, id int) (*[]structures.Flat, error) {
-	c.log.Info("cache start")
-	var err error
-	data, err := c.conn.Get(fmt.Sprintf("%s:%d", moderatorAll, id))
-	if errors.Is(err, bigcache.ErrEntryNotFound) {
-		var err error
-		list, err := c.source.GetListByModerator(ctx, id)
-		if err != nil {
-			c.log.Error("failed to cache list flats client")
-			return nil, err
-		}
+func (c Client) DeleteSavedSearch(ctx context.Context, userId uuid.UUID, id int) (bool, error) {
+	return c.source.DeleteSavedSearch(ctx, userId, id)
+}
+
+func (c Client) FindSearchMatches(ctx context.Context, flatIds []int) (*[]structures.SearchMatch, error) {
+	result, err := c.source.FindSearchMatches(ctx, flatIds)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) QueueSearchAlert(ctx context.Context, flatId int) error {
+	return c.source.QueueSearchAlert(ctx, flatId)
+}
+
+func (c Client) ClaimSearchAlerts(ctx context.Context, limit int, lease time.Duration) (*[]int, error) {
+	result, err := c.source.ClaimSearchAlerts(ctx, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) DeleteSearchAlerts(ctx context.Context, flatIds []int) error {
+	return c.source.DeleteSearchAlerts(ctx, flatIds)
+}
+
+func (c Client) GetPreferences(ctx context.Context, userId uuid.UUID) (*structures.Preferences, error) {
+	return c.source.GetPreferences(ctx, userId)
+}
+
+func (c Client) GetRecipient(ctx context.Context, userId uuid.UUID) (*structures.Recipient, error) {
+	return c.source.GetRecipient(ctx, userId)
+}
+
+func (c Client) SetDelivery(ctx context.Context, userId uuid.UUID, delivery string) (bool, error) {
+	return c.source.SetDelivery(ctx, userId, delivery)
+}
+
+func (c Client) SetLocale(ctx context.Context, userId uuid.UUID, locale string) (bool, error) {
+	return c.source.SetLocale(ctx, userId, locale)
+}
 
-		flats := house.GetListResponse{Flats: list}
+func (c Client) SavePendingNotification(ctx context.Context, userId uuid.UUID, message string) error {
+	return c.source.SavePendingNotification(ctx, userId, message)
+}
 
-		resp, err := json.Marshal(flats)
+func (c Client) TakePendingNotifications(
+	ctx context.Context, deliveries []string,
+) (*[]structures.PendingNotification, error) {
+	result, err := c.source.TakePendingNotifications(ctx, deliveries)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) EnqueueEmailJob(ctx context.Context, key, recipient, message string) (bool, error) {
+	return c.source.EnqueueEmailJob(ctx, key, recipient, message)
+}
+
+func (c Client) ClaimEmailJobs(ctx context.Context, limit int, lease time.Duration) (*[]structures.EmailJob, error) {
+	result, err := c.source.ClaimEmailJobs(ctx, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) UpdateEmailJob(ctx context.Context, job structures.EmailJob) (bool, error) {
+	return c.source.UpdateEmailJob(ctx, job)
+}
+
+func (c Client) DeleteSentEmailJobs(ctx context.Context, before time.Time) (int64, error) {
+	return c.source.DeleteSentEmailJobs(ctx, before)
+}
+
+func (c Client) CountEmailJobs(ctx context.Context) (*[]structures.EmailJobCount, error) {
+	result, err := c.source.CountEmailJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) SaveWebhook(ctx context.Context, webhook structures.Webhook) (*structures.Webhook, error) {
+	result, err := c.source.SaveWebhook(ctx, webhook)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) GetWebhook(ctx context.Context, id int) (*structures.Webhook, error) {
+	result, err := c.source.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) GetWebhooks(ctx context.Context, ownerId uuid.UUID) (*[]structures.Webhook, error) {
+	result, err := c.source.GetWebhooks(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) DeleteWebhook(ctx context.Context, ownerId uuid.UUID, id int) (bool, error) {
+	return c.source.DeleteWebhook(ctx, ownerId, id)
+}
+
+func (c Client) EnqueueWebhookDeliveries(ctx context.Context, event string, payload []byte) error {
+	return c.source.EnqueueWebhookDeliveries(ctx, event, payload)
+}
+
+func (c Client) ClaimWebhookDeliveries(
+	ctx context.Context, limit int, lease time.Duration,
+) (*[]structures.WebhookDelivery, error) {
+	result, err := c.source.ClaimWebhookDeliveries(ctx, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) UpdateWebhookDelivery(ctx context.Context, delivery structures.WebhookDelivery) error {
+	return c.source.UpdateWebhookDelivery(ctx, delivery)
+}
+
+func (c Client) GetWebhookDeliveries(
+	ctx context.Context, webhookId, beforeId, limit int,
+) (*[]structures.WebhookDelivery, error) {
+	result, err := c.source.GetWebhookDeliveries(ctx, webhookId, beforeId, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) ClaimFlat(ctx context.Context, id int, moderatorId uuid.UUID) (*structures.Flat, error) {
+	flat, err := c.source.ClaimFlat(ctx, id, moderatorId)
+	if err != nil {
+		return nil, err
+	}
+
+	c.invalidateHouse(ctx, flat.HouseId)
+	return flat, nil
+}
+
+func (c Client) ReleaseFlat(ctx context.Context, id int, moderatorId uuid.UUID) (*structures.Flat, error) {
+	flat, err := c.source.ReleaseFlat(ctx, id, moderatorId)
+	if err != nil {
+		return nil, err
+	}
+
+	c.invalidateHouse(ctx, flat.HouseId)
+	return flat, nil
+}
+
+func (c Client) FindDuplicateFlats(
+	ctx context.Context, flat structures.Flat, priceFrom, priceTo int,
+) (*[]structures.Flat, error) {
+	result, err := c.source.FindDuplicateFlats(ctx, flat, priceFrom, priceTo)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) GetDuplicateClusters(
+	ctx context.Context, afterId, limit int,
+) (*[]structures.DuplicateCluster, error) {
+	result, err := c.source.GetDuplicateClusters(ctx, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c Client) GetListByClient(ctx context.Context, id int, filter structures.FlatFilter) (*[]structures.Flat, error) {
+	return c.source.GetListByClient(ctx, id, filter)
+}
+
+func (c Client) GetListByModerator(ctx context.Context, id int, filter structures.FlatFilter) (*[]structures.Flat, error) {
+	return c.source.GetListByModerator(ctx, id, filter)
+}
+
+// GetListPage returns the serialized list of the house flats for clients or
+// moderators, the default page is cached as the final response bytes.
+func (c Client) GetListPage(
+	ctx context.Context, id int, filter structures.FlatFilter, moderator bool,
+) (*services.Page, error) {
+	key := fmt.Sprintf("%s:%d", clientAll, id)
+	load := c.source.GetListByClient
+	if moderator {
+		key = fmt.Sprintf("%s:%d", moderatorAll, id)
+		load = c.source.GetListByModerator
+	}
+	query := func(ctx context.Context) (*services.Page, error) {
+		list, err := load(ctx, id, filter)
 		if err != nil {
-			c.log.Error("failed to cache list flats client")
 			return nil, err
 		}
+		return services.NewPage(list, filter)
+	}
 
-		if err = c.conn.Set(fmt.Sprintf("%s:%d", moderatorAll, id), resp); err != nil {
-			c.log.Error("failed to cache list flats client")
-			return nil, err
-		}
-		c.log.Info("cache end")
-		return list, nil
+	if !isDefaultPage(filter) {
+		return query(ctx)
+	}
+	return c.cachedPage(ctx, key, query)
+}
+
+type pageLoader func(ctx context.Context) (*services.Page, error)
+
+// cachedPage returns the cached page of the key. Concurrent misses of the key
+// share a single query, and a page older than the soft TTL is served while a
+// single background query refreshes it.
+func (c Client) cachedPage(ctx context.Context, key string, load pageLoader) (*services.Page, error) {
+	data, at, err := c.get(ctx, key)
+	if errors.Is(err, cachestore.ErrNotFound) {
+		return c.loadPage(ctx, key, load)
 	}
 	if err != nil {
-		c.log.Error("failed to cache list flats client")
+		c.log.Error("failed to get list of flats from cache", slog.String("key", key), slog.Any("error", err))
+		return c.loadPage(ctx, key, load)
+	}
+
+	page, ok := decodePage(data)
+	if !ok {
+		c.log.Error("invalid cached list of flats", slog.String("key", key))
+		return c.loadPage(ctx, key, load)
+	}
+
+	if c.softTTL > 0 && time.Since(at) > c.softTTL {
+		// the result is not awaited, the refresh must outlive the request
+		c.flight.DoChan(key, func() (interface{}, error) {
+			return c.refreshPage(context.WithoutCancel(ctx), key, load)
+		})
+	}
+	return page, nil
+}
+
+// loadPage queries the page once for all the concurrent callers.
+func (c Client) loadPage(ctx context.Context, key string, load pageLoader) (*services.Page, error) {
+	page, err, _ := c.flight.Do(key, func() (interface{}, error) {
+		return c.refreshPage(context.WithoutCancel(ctx), key, load)
+	})
+	if err != nil {
 		return nil, err
 	}
+	return page.(*services.Page), nil
+}
 
-	var result house.GetListResponse
-	err = json.Unmarshal(data, &result)
+// refreshPage queries the page and caches it. A page queried before an
+// invalidation of its key may miss the change, so it is not cached. An
+// invalidation may also delete the key before the page is set, then the
+// page is deleted after it.
+func (c Client) refreshPage(ctx context.Context, key string, load pageLoader) (*services.Page, error) {
+	epoch := c.epochs.get(key)
+	page, err := load(ctx)
 	if err != nil {
-		c.log.Error("failed to cache list flats client")
 		return nil, err
 	}
-	c.log.Info("cache end")
+	if c.epochs.get(key) != epoch {
+		return page, nil
+	}
+	if err = c.set(ctx, key, encodePage(page)); err != nil {
+		c.log.Error("failed to cache list of flats", slog.String("key", key), slog.Any("error", err))
+		return page, nil
+	}
+	if c.epochs.get(key) != epoch {
+		if err = c.conn.Delete(ctx, key); err != nil {
+			c.log.Error("failed to delete list of flats from cache", slog.String("key", key), slog.Any("error", err))
+		}
+	}
+	return page, nil
+}
+
+// etagSize is the size of the ETag the cached pages start with.
+var etagSize = len(services.ETag(nil))
+
+func encodePage(page *services.Page) []byte {
+	return append([]byte(page.ETag), page.Body...)
+}
+
+func decodePage(data []byte) (*services.Page, bool) {
+	if len(data) < etagSize {
+		return nil, false
+	}
+	return &services.Page{ETag: string(data[:etagSize]), Body: data[etagSize:]}, true
+}
+
+// invalidateHouse drops the cached flat lists of the house here and on the
+// other replicas, every method changing a flat or a house calls it with the
+// house of the change.
+func (c Client) invalidateHouse(ctx context.Context, houseId int) {
+	c.evictHouse(ctx, houseId)
+	c.publish(ctx, houseId)
+}
+
+func (c Client) evictHouse(ctx context.Context, houseId int) {
+	for _, prefix := range []string{clientAll, moderatorAll} {
+		key := fmt.Sprintf("%s:%d", prefix, houseId)
+		c.epochs.bump(key)
+		// the requests after the invalidation do not join a query started
+		// before it
+		c.flight.Forget(key)
+		if err := c.conn.Delete(ctx, key); err != nil {
+			c.log.Error(
+				"failed to delete list of flats from cache",
+				slog.String("key", key),
+				slog.Int("house_id", houseId),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+// get returns the cached value with the time it was cached, the entries
+// older than the allowed age are reported as missing.
+func (c Client) get(ctx context.Context, key string) ([]byte, time.Time, error) {
+	entry, err := c.conn.Get(ctx, key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	at, value, ok := unstamp(entry)
+	if !ok {
+		return nil, time.Time{}, cachestore.ErrNotFound
+	}
+	if maxAge := c.maxAge(); maxAge > 0 && time.Since(at) > maxAge {
+		return nil, time.Time{}, cachestore.ErrNotFound
+	}
+	return value, at, nil
+}
+
+func (c Client) set(ctx context.Context, key string, value []byte) error {
+	return c.conn.Set(ctx, key, stamp(value, time.Now()))
+}
 
-	return result.Flats, nil
+func isDefaultPage(filter structures.FlatFilter) bool {
+	return filter.Unfiltered() && filter.Limit == defaultPageRows
 }
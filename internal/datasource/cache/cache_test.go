@@ -0,0 +1,484 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/cachestore"
+	"github.com/google/uuid"
+)
+
+// fakeSource keeps flats in memory and counts the list queries per house.
+// The methods the tests do not use panic through the nil Datasource.
+type fakeSource struct {
+	datasource.Datasource
+
+	mu    sync.Mutex
+	flats map[int]structures.Flat
+	lists map[int]int
+	// gate holds the list queries until it is closed when it is set
+	gate chan struct{}
+}
+
+func newFakeSource(flats ...structures.Flat) *fakeSource {
+	s := &fakeSource{flats: make(map[int]structures.Flat), lists: make(map[int]int)}
+	for _, flat := range flats {
+		s.flats[flat.Id] = flat
+	}
+	return s
+}
+
+func (s *fakeSource) listQueries(houseId int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lists[houseId]
+}
+
+func (s *fakeSource) list(houseId int, status string) *[]structures.Flat {
+	s.mu.Lock()
+	s.lists[houseId]++
+	flats := make([]structures.Flat, 0)
+	for _, flat := range s.flats {
+		if flat.HouseId == houseId && (status == "" || flat.Status == status) {
+			flats = append(flats, flat)
+		}
+	}
+	gate := s.gate
+	s.mu.Unlock()
+
+	sort.Slice(flats, func(i, j int) bool { return flats[i].Id < flats[j].Id })
+	// the query sees the flats as they were when it started
+	if gate != nil {
+		<-gate
+	}
+	return &flats
+}
+
+func (s *fakeSource) update(id int, change func(flat *structures.Flat)) *structures.Flat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flat := s.flats[id]
+	change(&flat)
+	s.flats[id] = flat
+	return &flat
+}
+
+func (s *fakeSource) GetListByClient(
+	_ context.Context, id int, _ structures.FlatFilter,
+) (*[]structures.Flat, error) {
+	return s.list(id, "approved"), nil
+}
+
+func (s *fakeSource) GetListByModerator(
+	_ context.Context, id int, _ structures.FlatFilter,
+) (*[]structures.Flat, error) {
+	return s.list(id, ""), nil
+}
+
+func (s *fakeSource) GetFlat(_ context.Context, id int) (*structures.Flat, error) {
+	return s.update(id, func(*structures.Flat) {}), nil
+}
+
+func (s *fakeSource) SaveHouse(_ context.Context, house structures.House) (*structures.House, error) {
+	return &house, nil
+}
+
+func (s *fakeSource) UpdateDate(context.Context, time.Time, int) error {
+	return nil
+}
+
+func (s *fakeSource) SaveFlat(_ context.Context, flat structures.Flat) (*structures.Flat, error) {
+	return s.update(flat.Id, func(f *structures.Flat) { *f = flat }), nil
+}
+
+func (s *fakeSource) SaveFlatPhoto(_ context.Context, photo structures.Photo) (*structures.Photo, error) {
+	return &photo, nil
+}
+
+func (s *fakeSource) UpdateStatus(
+	_ context.Context, id int, status string, _ uuid.UUID,
+) (*structures.Flat, error) {
+	return s.update(id, func(f *structures.Flat) { f.Status = status }), nil
+}
+
+func (s *fakeSource) UpdateFlat(_ context.Context, flat structures.Flat) (*structures.Flat, error) {
+	return s.update(flat.Id, func(f *structures.Flat) { f.Price = flat.Price }), nil
+}
+
+func (s *fakeSource) UpdateAvailability(
+	_ context.Context, id int, _, to string,
+) (*structures.Flat, error) {
+	return s.update(id, func(f *structures.Flat) { f.Availability = to }), nil
+}
+
+func (s *fakeSource) ClaimFlat(_ context.Context, id int, moderatorId uuid.UUID) (*structures.Flat, error) {
+	return s.update(id, func(f *structures.Flat) {
+		f.Status, f.ModeratorId = "on moderation", &moderatorId
+	}), nil
+}
+
+func (s *fakeSource) ReleaseFlat(_ context.Context, id int, _ uuid.UUID) (*structures.Flat, error) {
+	return s.update(id, func(f *structures.Flat) {
+		f.Status, f.ModeratorId = "created", nil
+	}), nil
+}
+
+func newTestClient(t *testing.T, source *fakeSource) *Client {
+	t.Helper()
+	return newRevalidatingClient(t, source, 0)
+}
+
+func newRevalidatingClient(t *testing.T, source *fakeSource, softTTL time.Duration) *Client {
+	t.Helper()
+	return NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), cachestore.NewLRU(100, time.Minute), source, softTTL)
+}
+
+var defaultPage = structures.FlatFilter{Limit: defaultPageRows}
+
+// getList reads the default page of the house list.
+func getList(ctx context.Context, c *Client, houseId int, moderator bool) (*[]structures.Flat, error) {
+	page, err := c.GetListPage(ctx, houseId, defaultPage, moderator)
+	if err != nil {
+		return nil, err
+	}
+	if page.ETag != services.ETag(page.Body) {
+		return nil, fmt.Errorf("ETag %s does not match the body", page.ETag)
+	}
+	var response services.GetListResponse
+	if err = json.Unmarshal(page.Body, &response); err != nil {
+		return nil, err
+	}
+	return response.Flats, nil
+}
+
+// readLists reads the cached lists of the house for clients and moderators.
+func readLists(t *testing.T, c *Client, houseId int) (client, moderator *[]structures.Flat) {
+	t.Helper()
+	client, err := getList(context.Background(), c, houseId, false)
+	if err != nil {
+		t.Fatalf("GetListPage: %v", err)
+	}
+	moderator, err = getList(context.Background(), c, houseId, true)
+	if err != nil {
+		t.Fatalf("GetListPage: %v", err)
+	}
+	return client, moderator
+}
+
+// The flat ids differ from the house ids so a key built from the flat id
+// misses the lists of the house.
+const (
+	houseId      = 1
+	otherHouseId = 2
+	flatId       = 10
+	otherFlatId  = 20
+)
+
+func TestMutationsRefreshHouseLists(t *testing.T) {
+	moderatorId := uuid.New()
+	tests := []struct {
+		name   string
+		mutate func(ctx context.Context, c *Client) error
+	}{
+		{"SaveHouse", func(ctx context.Context, c *Client) error {
+			_, err := c.SaveHouse(ctx, structures.House{Id: houseId})
+			return err
+		}},
+		{"UpdateDate", func(ctx context.Context, c *Client) error {
+			return c.UpdateDate(ctx, time.Now(), houseId)
+		}},
+		{"SaveFlat", func(ctx context.Context, c *Client) error {
+			_, err := c.SaveFlat(ctx, structures.Flat{Id: 11, HouseId: houseId, Status: "created"})
+			return err
+		}},
+		{"SaveFlatPhoto", func(ctx context.Context, c *Client) error {
+			_, err := c.SaveFlatPhoto(ctx, structures.Photo{FlatId: flatId})
+			return err
+		}},
+		{"UpdateStatus", func(ctx context.Context, c *Client) error {
+			_, err := c.UpdateStatus(ctx, flatId, "declined", moderatorId)
+			return err
+		}},
+		{"UpdateFlat", func(ctx context.Context, c *Client) error {
+			_, err := c.UpdateFlat(ctx, structures.Flat{Id: flatId, Price: 900})
+			return err
+		}},
+		{"UpdateAvailability", func(ctx context.Context, c *Client) error {
+			_, err := c.UpdateAvailability(ctx, flatId, "active", "sold")
+			return err
+		}},
+		{"ClaimFlat", func(ctx context.Context, c *Client) error {
+			_, err := c.ClaimFlat(ctx, flatId, moderatorId)
+			return err
+		}},
+		{"ReleaseFlat", func(ctx context.Context, c *Client) error {
+			_, err := c.ReleaseFlat(ctx, flatId, moderatorId)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeSource(
+				structures.Flat{Id: flatId, HouseId: houseId, Price: 1000, Status: "approved"},
+				structures.Flat{Id: otherFlatId, HouseId: otherHouseId, Price: 1000, Status: "approved"},
+			)
+			c := newTestClient(t, source)
+
+			readLists(t, c, houseId)
+			readLists(t, c, otherHouseId)
+			readLists(t, c, houseId)
+			if got := source.listQueries(houseId); got != 2 {
+				t.Fatalf("lists are not cached: %d queries, want 2", got)
+			}
+
+			if err := tt.mutate(context.Background(), c); err != nil {
+				t.Fatalf("mutation failed: %v", err)
+			}
+
+			readLists(t, c, houseId)
+			if got := source.listQueries(houseId); got != 4 {
+				t.Errorf("house lists are not refreshed: %d queries, want 4", got)
+			}
+			readLists(t, c, otherHouseId)
+			if got := source.listQueries(otherHouseId); got != 2 {
+				t.Errorf("lists of another house are refreshed: %d queries, want 2", got)
+			}
+		})
+	}
+}
+
+func TestApprovedFlatAppearsInClientList(t *testing.T) {
+	source := newFakeSource(structures.Flat{Id: flatId, HouseId: houseId, Price: 1000, Status: "created"})
+	c := newTestClient(t, source)
+	ctx := context.Background()
+
+	client, _ := readLists(t, c, houseId)
+	if len(*client) != 0 {
+		t.Fatalf("client list has %d flats before approval, want 0", len(*client))
+	}
+
+	if _, err := c.UpdateStatus(ctx, flatId, "approved", uuid.Nil); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+
+	client, moderator := readLists(t, c, houseId)
+	if len(*client) != 1 || (*client)[0].Id != flatId {
+		t.Fatalf("client list after approval = %+v, want flat %d", *client, flatId)
+	}
+	if (*moderator)[0].Status != "approved" {
+		t.Errorf("moderator list status = %q, want approved", (*moderator)[0].Status)
+	}
+}
+
+func TestConcurrentMissesShareQuery(t *testing.T) {
+	source := newFakeSource(structures.Flat{Id: flatId, HouseId: houseId, Price: 1000, Status: "approved"})
+	source.gate = make(chan struct{})
+	c := newTestClient(t, source)
+
+	const requests = 50
+	var wg sync.WaitGroup
+	results := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			list, err := getList(context.Background(), c, houseId, false)
+			if err != nil {
+				t.Errorf("GetListPage: %v", err)
+				return
+			}
+			results <- len(*list)
+		}()
+	}
+
+	// wait for the first query, the other requests join it
+	for source.listQueries(houseId) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(source.gate)
+	wg.Wait()
+	close(results)
+
+	for n := range results {
+		if n != 1 {
+			t.Fatalf("request got %d flats, want 1", n)
+		}
+	}
+	if got := source.listQueries(houseId); got != 1 {
+		t.Errorf("list queries = %d, want 1", got)
+	}
+}
+
+func TestStaleListIsServedWhileRefreshed(t *testing.T) {
+	source := newFakeSource(structures.Flat{Id: flatId, HouseId: houseId, Price: 1000, Status: "approved"})
+	c := newRevalidatingClient(t, source, 10*time.Millisecond)
+	ctx := context.Background()
+
+	if _, err := getList(ctx, c, houseId, false); err != nil {
+		t.Fatalf("GetListPage: %v", err)
+	}
+	// a change the cache does not know about
+	source.update(flatId, func(f *structures.Flat) { f.Price = 900 })
+	source.mu.Lock()
+	source.gate = make(chan struct{})
+	source.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+
+	// the stale list is served at once while the refresh waits for the gate
+	list, err := getList(ctx, c, houseId, false)
+	if err != nil {
+		t.Fatalf("GetListPage: %v", err)
+	}
+	if (*list)[0].Price != 1000 {
+		t.Fatalf("stale list price = %d, want 1000", (*list)[0].Price)
+	}
+	for source.listQueries(houseId) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err = getList(ctx, c, houseId, false); err != nil {
+		t.Fatalf("GetListPage: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := source.listQueries(houseId); got != 2 {
+		t.Fatalf("list queries = %d, want a single refresh", got)
+	}
+	close(source.gate)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		list, err = getList(ctx, c, houseId, false)
+		if err != nil {
+			t.Fatalf("GetListPage: %v", err)
+		}
+		if (*list)[0].Price == 900 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the list is not refreshed, price = %d", (*list)[0].Price)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestInvalidationDuringQueryIsNotCached(t *testing.T) {
+	source := newFakeSource(structures.Flat{Id: flatId, HouseId: houseId, Price: 1000, Status: "created"})
+	gate := make(chan struct{})
+	source.gate = gate
+	c := newTestClient(t, source)
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = getList(ctx, c, houseId, false)
+	}()
+	for source.listQueries(houseId) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the query started before the approval may return the old list
+	source.mu.Lock()
+	source.gate = nil
+	source.mu.Unlock()
+	if _, err := c.UpdateStatus(ctx, flatId, "approved", uuid.Nil); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	close(gate)
+	<-done
+
+	list, err := getList(ctx, c, houseId, false)
+	if err != nil {
+		t.Fatalf("GetListPage: %v", err)
+	}
+	if len(*list) != 1 {
+		t.Fatalf("client list after approval has %d flats, want 1", len(*list))
+	}
+}
+
+func TestInvalidationOfOtherHouseDuringQueryIsCached(t *testing.T) {
+	source := newFakeSource(
+		structures.Flat{Id: flatId, HouseId: houseId, Price: 1000, Status: "approved"},
+		structures.Flat{Id: otherFlatId, HouseId: otherHouseId, Price: 2000, Status: "approved"},
+	)
+	gate := make(chan struct{})
+	source.gate = gate
+	c := newTestClient(t, source)
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = getList(ctx, c, houseId, false)
+	}()
+	for source.listQueries(houseId) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	source.mu.Lock()
+	source.gate = nil
+	source.mu.Unlock()
+	if _, err := c.UpdateFlat(ctx, structures.Flat{Id: otherFlatId, Price: 1500}); err != nil {
+		t.Fatalf("UpdateFlat: %v", err)
+	}
+	close(gate)
+	<-done
+
+	if _, err := getList(ctx, c, houseId, false); err != nil {
+		t.Fatalf("GetListPage: %v", err)
+	}
+	if queries := source.listQueries(houseId); queries != 1 {
+		t.Errorf("house list queried %d times, want the first query cached", queries)
+	}
+}
+
+// hookedStore runs beforeSet once before the first value is stored.
+type hookedStore struct {
+	cachestore.Store
+	beforeSet func()
+}
+
+func (s *hookedStore) Set(ctx context.Context, key string, value []byte) error {
+	if hook := s.beforeSet; hook != nil {
+		s.beforeSet = nil
+		hook()
+	}
+	return s.Store.Set(ctx, key, value)
+}
+
+func TestInvalidationBeforeSetIsNotCached(t *testing.T) {
+	source := newFakeSource(structures.Flat{Id: flatId, HouseId: houseId, Price: 1000, Status: "created"})
+	store := &hookedStore{Store: cachestore.NewLRU(100, time.Minute)}
+	c := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), store, source, 0)
+	ctx := context.Background()
+
+	// the approval deletes the key after the epoch check, the old list is
+	// set after it
+	store.beforeSet = func() {
+		if _, err := c.UpdateStatus(ctx, flatId, "approved", uuid.Nil); err != nil {
+			t.Errorf("UpdateStatus: %v", err)
+		}
+	}
+	if _, err := getList(ctx, c, houseId, false); err != nil {
+		t.Fatalf("GetListPage: %v", err)
+	}
+
+	list, err := getList(ctx, c, houseId, false)
+	if err != nil {
+		t.Fatalf("GetListPage: %v", err)
+	}
+	if len(*list) != 1 {
+		t.Fatalf("client list after approval has %d flats, want 1", len(*list))
+	}
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/db"
+	"github.com/google/uuid"
+)
+
+const (
+	invalidationChannel = "flat_lists_invalidation"
+	invalidationRetry   = 5 * time.Second
+)
+
+type notifier interface {
+	Notify(ctx context.Context, channel, payload string) error
+	Listen(ctx context.Context, channel string, retry time.Duration, listener db.Listener) error
+}
+
+type invalidationMessage struct {
+	Origin  string `json:"origin"`
+	HouseId int    `json:"house_id"`
+}
+
+// replication shares the invalidations of the house lists between the
+// replicas with Postgres LISTEN/NOTIFY. While the listener is disconnected
+// the invalidations of other replicas are lost, so the cached lists are
+// served only for the fallback TTL and are dropped on reconnect.
+type replication struct {
+	db          notifier
+	origin      string
+	fallbackTTL time.Duration
+	connected   atomic.Bool
+}
+
+// ShareInvalidations makes the client publish its invalidations to other
+// replicas and evict the lists invalidated by them once Run is started.
+func (c *Client) ShareInvalidations(db notifier, fallbackTTL time.Duration) {
+	c.replication = &replication{db: db, origin: uuid.NewString(), fallbackTTL: fallbackTTL}
+}
+
+// Run evicts the house lists invalidated by other replicas until ctx is
+// done, it returns at once when the invalidations are not shared.
+func (c *Client) Run(ctx context.Context) {
+	if c.replication == nil {
+		return
+	}
+	_ = c.replication.db.Listen(ctx, invalidationChannel, invalidationRetry, invalidationListener{c})
+}
+
+// publish tells other replicas to evict the house lists.
+func (c Client) publish(ctx context.Context, houseId int) {
+	if c.replication == nil {
+		return
+	}
+	payload, err := json.Marshal(invalidationMessage{Origin: c.replication.origin, HouseId: houseId})
+	if err != nil {
+		c.log.Error("failed to marshal cache invalidation", slog.Any("error", err))
+		return
+	}
+	if err = c.replication.db.Notify(ctx, invalidationChannel, string(payload)); err != nil {
+		c.log.Error(
+			"failed to publish cache invalidation",
+			slog.Int("house_id", houseId),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// maxAge is the age of the cached lists that can be served, zero means no
+// limit besides the cache life window.
+func (c Client) maxAge() time.Duration {
+	if c.replication == nil || c.replication.connected.Load() {
+		return 0
+	}
+	return c.replication.fallbackTTL
+}
+
+type invalidationListener struct {
+	c *Client
+}
+
+// Connected drops the whole cache, the invalidations sent while the
+// listener was disconnected are lost.
+func (l invalidationListener) Connected() {
+	if err := l.c.conn.Reset(context.Background()); err != nil {
+		l.c.log.Error("failed to reset cache", slog.Any("error", err))
+	}
+	l.c.replication.connected.Store(true)
+	l.c.log.Info("listening for cache invalidations of other replicas")
+}
+
+func (l invalidationListener) Disconnected(err error) {
+	l.c.replication.connected.Store(false)
+	l.c.log.Error(
+		"cache invalidation listener disconnected, falling back to short TTL",
+		slog.Duration("ttl", l.c.replication.fallbackTTL),
+		slog.Any("error", err),
+	)
+}
+
+func (l invalidationListener) Notification(payload string) {
+	var msg invalidationMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		l.c.log.Error("failed to unmarshal cache invalidation", slog.Any("error", err))
+		return
+	}
+	if msg.Origin == l.c.replication.origin {
+		return
+	}
+	l.c.evictHouse(context.Background(), msg.HouseId)
+}
+
+// stampSize is the size of the time prefix of the cached entries.
+const stampSize = 8
+
+// stamp prefixes the cached value with the time it was cached.
+func stamp(value []byte, at time.Time) []byte {
+	entry := make([]byte, stampSize, stampSize+len(value))
+	binary.BigEndian.PutUint64(entry, uint64(at.UnixNano()))
+	return append(entry, value...)
+}
+
+// unstamp splits the cached entry into the time it was cached and the value.
+func unstamp(entry []byte) (time.Time, []byte, bool) {
+	if len(entry) < stampSize {
+		return time.Time{}, nil, false
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(entry))), entry[stampSize:], true
+}
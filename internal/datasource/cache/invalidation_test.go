@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/db"
+	"github.com/google/uuid"
+)
+
+// fakeBroker passes the notifications to the listeners synchronously, like
+// Postgres it delivers them to the sender too.
+type fakeBroker struct {
+	mu        sync.Mutex
+	listeners []db.Listener
+}
+
+func (b *fakeBroker) Notify(_ context.Context, _, payload string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, listener := range b.listeners {
+		listener.Notification(payload)
+	}
+	return nil
+}
+
+func (b *fakeBroker) Listen(ctx context.Context, _ string, _ time.Duration, listener db.Listener) error {
+	b.mu.Lock()
+	b.listeners = append(b.listeners, listener)
+	b.mu.Unlock()
+	listener.Connected()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (b *fakeBroker) disconnect() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, listener := range b.listeners {
+		listener.Disconnected(context.Canceled)
+	}
+}
+
+// newReplica starts a client sharing the invalidations through the broker
+// and waits for it to listen.
+func newReplica(t *testing.T, source *fakeSource, broker *fakeBroker, fallbackTTL time.Duration) *Client {
+	t.Helper()
+	c := newTestClient(t, source)
+	c.ShareInvalidations(broker, fallbackTTL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go c.Run(ctx)
+	for !c.replication.connected.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	return c
+}
+
+func TestInvalidationReachesOtherReplicas(t *testing.T) {
+	source := newFakeSource(structures.Flat{Id: flatId, HouseId: houseId, Price: 1000, Status: "created"})
+	broker := &fakeBroker{}
+	first := newReplica(t, source, broker, time.Minute)
+	second := newReplica(t, source, broker, time.Minute)
+
+	readLists(t, first, houseId)
+	readLists(t, second, houseId)
+	if got := source.listQueries(houseId); got != 4 {
+		t.Fatalf("list queries = %d, want 4", got)
+	}
+
+	if _, err := first.UpdateStatus(context.Background(), flatId, "approved", uuid.Nil); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+
+	client, _ := readLists(t, second, houseId)
+	if len(*client) != 1 {
+		t.Fatalf("client list of another replica has %d flats, want 1", len(*client))
+	}
+	if got := source.listQueries(houseId); got != 6 {
+		t.Errorf("list queries = %d, want 6", got)
+	}
+}
+
+func TestDisconnectedReplicaUsesFallbackTTL(t *testing.T) {
+	source := newFakeSource(structures.Flat{Id: flatId, HouseId: houseId, Price: 1000, Status: "approved"})
+	broker := &fakeBroker{}
+	c := newReplica(t, source, broker, 20*time.Millisecond)
+
+	readLists(t, c, houseId)
+	readLists(t, c, houseId)
+	if got := source.listQueries(houseId); got != 2 {
+		t.Fatalf("connected replica does not cache: %d queries, want 2", got)
+	}
+
+	broker.disconnect()
+	time.Sleep(30 * time.Millisecond)
+
+	readLists(t, c, houseId)
+	if got := source.listQueries(houseId); got != 4 {
+		t.Errorf("disconnected replica serves lists older than the fallback TTL: %d queries, want 4", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/geohash"
 	"github.com/google/uuid"
 )
 
@@ -12,6 +13,11 @@ type Datasource interface {
 	User
 	House
 	Flat
+	Photo
+	Favorite
+	SavedSearch
+	Notification
+	Webhook
 	GetList
 }
 
@@ -21,18 +27,79 @@ type User interface {
 }
 
 type House interface {
-	SaveHouse(ctx context.Context, address, developer string, year int) (*structures.House, error)
+	SaveHouse(ctx context.Context, house structures.House) (*structures.House, error)
 	GetHouse(ctx context.Context, id int) (*structures.House, error)
 	UpdateDate(ctx context.Context, time time.Time, id int) error
+	GetHouses(ctx context.Context, filter structures.HouseFilter) (*[]structures.House, error)
+	SuggestAddresses(ctx context.Context, text string, limit int) (*[]structures.AddressSuggestion, error)
+	GetHousesNearby(
+		ctx context.Context, lat, lon, radius float64, cells []string, limit int,
+	) (*[]structures.HouseDistance, error)
+	GetHousesInBox(ctx context.Context, box geohash.Box, cells []string, limit int) (*[]structures.House, error)
 }
 
 type Flat interface {
-	SaveFlat(ctx context.Context, houseId, price, rooms int) (*structures.Flat, error)
+	SaveFlat(ctx context.Context, flat structures.Flat) (*structures.Flat, error)
 	GetFlat(ctx context.Context, id int) (*structures.Flat, error)
-	UpdateStatus(ctx context.Context, id int, status string) error
+	UpdateStatus(ctx context.Context, id int, status string, moderatorId uuid.UUID) (*structures.Flat, error)
+	UpdateFlat(ctx context.Context, flat structures.Flat) (*structures.Flat, error)
+	UpdateAvailability(ctx context.Context, id int, from, to string) (*structures.Flat, error)
+	ClaimFlat(ctx context.Context, id int, moderatorId uuid.UUID) (*structures.Flat, error)
+	ReleaseFlat(ctx context.Context, id int, moderatorId uuid.UUID) (*structures.Flat, error)
+	GetPriceHistory(ctx context.Context, flatId int) (*[]structures.PriceChange, error)
+	FindDuplicateFlats(ctx context.Context, flat structures.Flat, priceFrom, priceTo int) (*[]structures.Flat, error)
+	GetDuplicateClusters(ctx context.Context, afterId, limit int) (*[]structures.DuplicateCluster, error)
+	SearchFlats(ctx context.Context, filter structures.FlatSearchFilter) (*[]structures.FlatSearchResult, error)
+	EstimateSearchFlats(ctx context.Context, filter structures.FlatSearchFilter) (int64, error)
 }
 
 type GetList interface {
-	GetListByClient(ctx context.Context, id int) (*[]structures.Flat, error)
-	GetListByModerator(ctx context.Context, id int) (*[]structures.Flat, error)
+	GetListByClient(ctx context.Context, id int, filter structures.FlatFilter) (*[]structures.Flat, error)
+	GetListByModerator(ctx context.Context, id int, filter structures.FlatFilter) (*[]structures.Flat, error)
+}
+
+type Photo interface {
+	SaveFlatPhoto(ctx context.Context, photo structures.Photo) (*structures.Photo, error)
+}
+
+type Favorite interface {
+	AddFavorite(ctx context.Context, userId uuid.UUID, flatId int) error
+	RemoveFavorite(ctx context.Context, userId uuid.UUID, flatId int) error
+	GetFavorites(ctx context.Context, userId uuid.UUID) (*[]structures.Flat, error)
+	ClaimPriceChangeRecipients(ctx context.Context, flatId, price int) (*[]structures.PriceChangeRecipient, error)
+}
+
+type SavedSearch interface {
+	SaveSearch(ctx context.Context, search structures.SavedSearch) (*structures.SavedSearch, error)
+	GetSavedSearches(ctx context.Context, userId uuid.UUID) (*[]structures.SavedSearch, error)
+	DeleteSavedSearch(ctx context.Context, userId uuid.UUID, id int) (bool, error)
+	FindSearchMatches(ctx context.Context, flatIds []int) (*[]structures.SearchMatch, error)
+	QueueSearchAlert(ctx context.Context, flatId int) error
+	ClaimSearchAlerts(ctx context.Context, limit int, lease time.Duration) (*[]int, error)
+	DeleteSearchAlerts(ctx context.Context, flatIds []int) error
+}
+
+type Notification interface {
+	GetPreferences(ctx context.Context, userId uuid.UUID) (*structures.Preferences, error)
+	GetRecipient(ctx context.Context, userId uuid.UUID) (*structures.Recipient, error)
+	SetDelivery(ctx context.Context, userId uuid.UUID, delivery string) (bool, error)
+	SetLocale(ctx context.Context, userId uuid.UUID, locale string) (bool, error)
+	SavePendingNotification(ctx context.Context, userId uuid.UUID, message string) error
+	TakePendingNotifications(ctx context.Context, deliveries []string) (*[]structures.PendingNotification, error)
+	EnqueueEmailJob(ctx context.Context, key, recipient, message string) (bool, error)
+	ClaimEmailJobs(ctx context.Context, limit int, lease time.Duration) (*[]structures.EmailJob, error)
+	UpdateEmailJob(ctx context.Context, job structures.EmailJob) (bool, error)
+	DeleteSentEmailJobs(ctx context.Context, before time.Time) (int64, error)
+	CountEmailJobs(ctx context.Context) (*[]structures.EmailJobCount, error)
+}
+
+type Webhook interface {
+	SaveWebhook(ctx context.Context, webhook structures.Webhook) (*structures.Webhook, error)
+	GetWebhook(ctx context.Context, id int) (*structures.Webhook, error)
+	GetWebhooks(ctx context.Context, ownerId uuid.UUID) (*[]structures.Webhook, error)
+	DeleteWebhook(ctx context.Context, ownerId uuid.UUID, id int) (bool, error)
+	EnqueueWebhookDeliveries(ctx context.Context, event string, payload []byte) error
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) (*[]structures.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery structures.WebhookDelivery) error
+	GetWebhookDeliveries(ctx context.Context, webhookId, beforeId, limit int) (*[]structures.WebhookDelivery, error)
 }
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+func TestUpdateAvailabilityChecksCurrentValue(t *testing.T) {
+	r := newTestStorage(t)
+	ctx := context.Background()
+	flat := createFlat(t, r, "created")
+
+	sold, err := r.UpdateAvailability(ctx, flat.Id, "active", "sold")
+	if err != nil {
+		t.Fatalf("UpdateAvailability: %v", err)
+	}
+	if sold.Availability != "sold" {
+		t.Errorf("availability = %s, want sold", sold.Availability)
+	}
+
+	// a request that read the flat before it was sold
+	if _, err = r.UpdateAvailability(ctx, flat.Id, "active", "withdrawn"); !errors.Is(err, structures.ErrAvailabilityChanged) {
+		t.Errorf("UpdateAvailability of a changed flat = %v, want %v", err, structures.ErrAvailabilityChanged)
+	}
+}
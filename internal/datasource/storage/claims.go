@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ClaimFlat puts a created flat on moderation by the moderator, the check
+// and the update are one statement so two moderators cannot claim the same
+// flat.
+func (r *Storage) ClaimFlat(ctx context.Context, id int, moderatorId uuid.UUID) (*structures.Flat, error) {
+	return r.updateClaim(
+		ctx,
+		`UPDATE flats SET status = 'on moderation', moderator_id = $2
+		WHERE id = $1 AND status = 'created' RETURNING `+flatColumns,
+		id,
+		moderatorId,
+	)
+}
+
+// ReleaseFlat returns a flat claimed by the moderator to the queue.
+func (r *Storage) ReleaseFlat(ctx context.Context, id int, moderatorId uuid.UUID) (*structures.Flat, error) {
+	return r.updateClaim(
+		ctx,
+		`UPDATE flats SET status = 'created', moderator_id = NULL
+		WHERE id = $1 AND status = 'on moderation' AND moderator_id = $2 RETURNING `+flatColumns,
+		id,
+		moderatorId,
+	)
+}
+
+// UpdateStatus sets the moderation status of the flat by the moderator, a
+// flat on moderation by another moderator is not changed. The status "on
+// moderation" claims the flat for the moderator, any other one releases it.
+// An approval remembers the price the flat is approved with.
+func (r *Storage) UpdateStatus(ctx context.Context, id int, status string, moderatorId uuid.UUID) (*structures.Flat, error) {
+	var flat structures.Flat
+	err := r.db.Get(
+		ctx,
+		&flat,
+		`UPDATE flats SET status = $3, moderator_id = CASE WHEN $3 = 'on moderation' THEN $2::uuid END,
+			approved_price = CASE WHEN $3 = 'approved' THEN price ELSE approved_price END
+		WHERE id = $1 AND (status <> 'on moderation' OR moderator_id IS NULL OR moderator_id = $2)
+		RETURNING `+flatColumns,
+		id,
+		moderatorId,
+		status,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, structures.ErrNotClaimable
+	}
+	if err != nil {
+		r.log.Error("database: failed to update status", slog.Any("error", err))
+		return nil, err
+	}
+	return &flat, nil
+}
+
+func (r *Storage) updateClaim(ctx context.Context, sql string, id int, moderatorId uuid.UUID) (*structures.Flat, error) {
+	var flat structures.Flat
+	err := r.db.Get(ctx, &flat, sql, id, moderatorId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, structures.ErrNotClaimable
+	}
+	if err != nil {
+		r.log.Error("database: failed to update flat claim", slog.Any("error", err))
+		return nil, err
+	}
+	return &flat, nil
+}
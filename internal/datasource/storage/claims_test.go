@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/db"
+	"github.com/google/uuid"
+)
+
+// newTestStorage connects to the migrated database of POSTGRES_DB_DSN, the
+// tests are skipped without it.
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	if _, ok := os.LookupEnv("POSTGRES_DB_DSN"); !ok {
+		t.Skip("POSTGRES_DB_DSN is not set")
+	}
+	database, err := db.NewDB(context.Background())
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { database.GetPool(context.Background()).Close() })
+	return New(database, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+}
+
+// createHouse saves a house and removes it with its flats after the test.
+func createHouse(t *testing.T, r *Storage) int {
+	t.Helper()
+	ctx := context.Background()
+	house, err := r.SaveHouse(ctx, structures.House{Address: "test " + uuid.NewString(), Developer: "test", Year: 2000})
+	if err != nil {
+		t.Fatalf("SaveHouse: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = r.db.Exec(ctx, "DELETE FROM flats WHERE house_id = $1", house.Id)
+		_, _ = r.db.Exec(ctx, "DELETE FROM houses WHERE id = $1", house.Id)
+	})
+	return house.Id
+}
+
+// createFlat saves a flat in a new house.
+func createFlat(t *testing.T, r *Storage, status string) *structures.Flat {
+	t.Helper()
+	flat, err := r.SaveFlat(
+		context.Background(), structures.Flat{HouseId: createHouse(t, r), Price: 1000, Rooms: 1, Status: status},
+	)
+	if err != nil {
+		t.Fatalf("SaveFlat: %v", err)
+	}
+	return flat
+}
+
+func TestClaimFlat(t *testing.T) {
+	r := newTestStorage(t)
+	ctx := context.Background()
+	flat := createFlat(t, r, "created")
+	first, second := uuid.New(), uuid.New()
+
+	claimed, err := r.ClaimFlat(ctx, flat.Id, first)
+	if err != nil {
+		t.Fatalf("ClaimFlat: %v", err)
+	}
+	if claimed.Status != "on moderation" || claimed.ModeratorId == nil || *claimed.ModeratorId != first {
+		t.Errorf("claimed flat = %s by %v, want on moderation by %s", claimed.Status, claimed.ModeratorId, first)
+	}
+
+	if _, err = r.ClaimFlat(ctx, flat.Id, second); !errors.Is(err, structures.ErrNotClaimable) {
+		t.Errorf("ClaimFlat of a claimed flat = %v, want %v", err, structures.ErrNotClaimable)
+	}
+}
+
+func TestReleaseFlat(t *testing.T) {
+	r := newTestStorage(t)
+	ctx := context.Background()
+	flat := createFlat(t, r, "created")
+	owner, other := uuid.New(), uuid.New()
+
+	if _, err := r.ReleaseFlat(ctx, flat.Id, owner); !errors.Is(err, structures.ErrNotClaimable) {
+		t.Errorf("ReleaseFlat of an unclaimed flat = %v, want %v", err, structures.ErrNotClaimable)
+	}
+	if _, err := r.ClaimFlat(ctx, flat.Id, owner); err != nil {
+		t.Fatalf("ClaimFlat: %v", err)
+	}
+	if _, err := r.ReleaseFlat(ctx, flat.Id, other); !errors.Is(err, structures.ErrNotClaimable) {
+		t.Errorf("ReleaseFlat by another moderator = %v, want %v", err, structures.ErrNotClaimable)
+	}
+
+	released, err := r.ReleaseFlat(ctx, flat.Id, owner)
+	if err != nil {
+		t.Fatalf("ReleaseFlat: %v", err)
+	}
+	if released.Status != "created" || released.ModeratorId != nil {
+		t.Errorf("released flat = %s by %v, want created without moderator", released.Status, released.ModeratorId)
+	}
+}
+
+func TestUpdateStatusKeepsOwnership(t *testing.T) {
+	r := newTestStorage(t)
+	ctx := context.Background()
+	flat := createFlat(t, r, "created")
+	owner, other := uuid.New(), uuid.New()
+
+	claimed, err := r.UpdateStatus(ctx, flat.Id, "on moderation", owner)
+	if err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	if claimed.ModeratorId == nil || *claimed.ModeratorId != owner {
+		t.Errorf("moderator = %v, want %s", claimed.ModeratorId, owner)
+	}
+
+	if _, err = r.UpdateStatus(ctx, flat.Id, "approved", other); !errors.Is(err, structures.ErrNotClaimable) {
+		t.Errorf("UpdateStatus by another moderator = %v, want %v", err, structures.ErrNotClaimable)
+	}
+
+	approved, err := r.UpdateStatus(ctx, flat.Id, "approved", owner)
+	if err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	if approved.Status != "approved" || approved.ModeratorId != nil {
+		t.Errorf("approved flat = %s by %v, want approved without moderator", approved.Status, approved.ModeratorId)
+	}
+	if approved.ApprovedPrice == nil || *approved.ApprovedPrice != flat.Price {
+		t.Errorf("approved price = %v, want %d", approved.ApprovedPrice, flat.Price)
+	}
+}
+
+func TestUpdateFlatStoresDecisionAndReleasesModerator(t *testing.T) {
+	r := newTestStorage(t)
+	ctx := context.Background()
+	flat := createFlat(t, r, "created")
+	if _, err := r.ClaimFlat(ctx, flat.Id, uuid.New()); err != nil {
+		t.Fatalf("ClaimFlat: %v", err)
+	}
+
+	edited := *flat
+	edited.Description = "banned words"
+	edited.Status, edited.ModerationReason = "declined", "banned words in the description"
+	updated, err := r.UpdateFlat(ctx, edited)
+	if err != nil {
+		t.Fatalf("UpdateFlat: %v", err)
+	}
+	if updated.Status != "declined" || updated.ModerationReason != edited.ModerationReason {
+		t.Errorf("updated flat = %s (%q), want the decision stored", updated.Status, updated.ModerationReason)
+	}
+	if updated.ModeratorId != nil {
+		t.Errorf("moderator = %v, want the edited flat released", updated.ModeratorId)
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/google/uuid"
+)
+
+func TestFindDuplicateFlats(t *testing.T) {
+	r := newTestStorage(t)
+	ctx := context.Background()
+	author, other := uuid.New(), uuid.New()
+	number := 12
+	original, err := r.SaveFlat(
+		ctx,
+		structures.Flat{
+			HouseId: createHouse(t, r), Price: 1000, Rooms: 1, Number: &number, AuthorId: &author, Status: "created",
+		},
+	)
+	if err != nil {
+		t.Fatalf("SaveFlat: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		flat structures.Flat
+		want bool
+	}{
+		{"same author", structures.Flat{AuthorId: &author, Number: &number}, true},
+		{"other author", structures.Flat{AuthorId: &other, Number: &number}, false},
+		{"no author", structures.Flat{Number: &number}, false},
+		{"no number", structures.Flat{AuthorId: &author}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flat := tt.flat
+			flat.HouseId, flat.Rooms = original.HouseId, original.Rooms
+			duplicates, err := r.FindDuplicateFlats(ctx, flat, original.Price, original.Price)
+			if err != nil {
+				t.Fatalf("FindDuplicateFlats: %v", err)
+			}
+			found := false
+			for _, duplicate := range *duplicates {
+				found = found || duplicate.Id == original.Id
+			}
+			if found != tt.want {
+				t.Errorf("found = %v, want %v", found, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/google/uuid"
+)
+
+const emailJobColumns = "id,idempotency_key,recipient,message,status,attempts,next_attempt_at,last_error," +
+	"created_at,updated_at,lease_token"
+
+// EnqueueEmailJob adds a pending job unless a job with the key exists, it
+// reports whether the job was added.
+func (r *Storage) EnqueueEmailJob(ctx context.Context, key, recipient, message string) (bool, error) {
+	tag, err := r.db.Exec(
+		ctx,
+		`INSERT INTO email_jobs(idempotency_key, recipient, message) VALUES ($1, $2, $3)
+		ON CONFLICT (idempotency_key) DO NOTHING`,
+		key,
+		recipient,
+		message,
+	)
+	if err != nil {
+		r.log.Error("database: failed to enqueue email job", slog.Any("error", err))
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ClaimEmailJobs returns the pending jobs due for an attempt, leased the same
+// way as the webhook deliveries. The jobs get a new lease token, so an
+// attempt that outlived its lease cannot overwrite the outcome of the next
+// claim.
+func (r *Storage) ClaimEmailJobs(ctx context.Context, limit int, lease time.Duration) (*[]structures.EmailJob, error) {
+	jobs := make([]structures.EmailJob, 0, limit)
+	err := r.db.Select(
+		ctx,
+		&jobs,
+		`WITH due AS (
+			SELECT id FROM email_jobs
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE email_jobs j SET next_attempt_at = NOW() + make_interval(secs => $2), lease_token = $3
+		FROM due WHERE j.id = due.id
+		RETURNING `+prefixColumns("j", emailJobColumns),
+		limit,
+		lease.Seconds(),
+		uuid.New(),
+	)
+	if err != nil {
+		r.log.Error("database: failed to claim email jobs", slog.Any("error", err))
+		return nil, err
+	}
+	return &jobs, nil
+}
+
+// UpdateEmailJob records the outcome of an attempt, it reports false when
+// the job was claimed again since and the outcome is dropped.
+func (r *Storage) UpdateEmailJob(ctx context.Context, job structures.EmailJob) (bool, error) {
+	tag, err := r.db.Exec(
+		ctx,
+		`UPDATE email_jobs SET status = $2, attempts = $3, next_attempt_at = $4, last_error = $5, updated_at = NOW(),
+			lease_token = NULL
+		WHERE id = $1 AND lease_token = $6`,
+		job.Id,
+		job.Status,
+		job.Attempts,
+		job.NextAttemptAt,
+		job.LastError,
+		job.LeaseToken,
+	)
+	if err != nil {
+		r.log.Error("database: failed to update email job", slog.Any("error", err))
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteSentEmailJobs removes the jobs sent before the time, it returns the
+// number of removed jobs.
+func (r *Storage) DeleteSentEmailJobs(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM email_jobs WHERE status = 'sent' AND updated_at < $1`, before)
+	if err != nil {
+		r.log.Error("database: failed to delete sent email jobs", slog.Any("error", err))
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *Storage) CountEmailJobs(ctx context.Context) (*[]structures.EmailJobCount, error) {
+	counts := make([]structures.EmailJobCount, 0)
+	err := r.db.Select(ctx, &counts, `SELECT status, COUNT(*) AS count FROM email_jobs GROUP BY status ORDER BY status`)
+	if err != nil {
+		r.log.Error("database: failed to count email jobs", slog.Any("error", err))
+		return nil, err
+	}
+	return &counts, nil
+}
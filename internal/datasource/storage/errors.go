@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// flatExists is the error of a flat taking the place of another flat of the
+// house, the number is optional.
+func flatExists(flat structures.Flat) error {
+	if flat.Number == nil {
+		return fmt.Errorf("flat in house %d: %w", flat.HouseId, structures.ErrAlreadyExists)
+	}
+	return fmt.Errorf("flat %d in house %d: %w", *flat.Number, flat.HouseId, structures.ErrAlreadyExists)
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+func TestFlatExists(t *testing.T) {
+	number := 12
+	tests := []struct {
+		flat structures.Flat
+		want string
+	}{
+		{structures.Flat{HouseId: 3, Number: &number}, "flat 12 in house 3: "},
+		{structures.Flat{HouseId: 3}, "flat in house 3: "},
+	}
+	for _, tt := range tests {
+		err := flatExists(tt.flat)
+		if !errors.Is(err, structures.ErrAlreadyExists) {
+			t.Errorf("flatExists() = %v, want %v", err, structures.ErrAlreadyExists)
+		}
+		if want := tt.want + structures.ErrAlreadyExists.Error(); err.Error() != want {
+			t.Errorf("flatExists() = %q, want %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/google/uuid"
+)
+
+func (r *Storage) AddFavorite(ctx context.Context, userId uuid.UUID, flatId int) error {
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO favorites(user_id, flat_id, notified_price)
+		SELECT $1, id, price FROM flats WHERE id = $2
+		ON CONFLICT DO NOTHING`,
+		userId,
+		flatId,
+	)
+	if err != nil {
+		r.log.Error("database: failed to add favorite", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (r *Storage) RemoveFavorite(ctx context.Context, userId uuid.UUID, flatId int) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM favorites WHERE user_id = $1 AND flat_id = $2`, userId, flatId)
+	if err != nil {
+		r.log.Error("database: failed to remove favorite", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+// GetFavorites returns the saved flats of the user, flats that are no longer
+// approved or active are hidden but the favorites are kept in case they
+// come back.
+func (r *Storage) GetFavorites(ctx context.Context, userId uuid.UUID) (*[]structures.Flat, error) {
+	flats := make([]structures.Flat, 0)
+	err := r.db.Select(
+		ctx,
+		&flats,
+		`SELECT `+prefixColumns("flats", flatColumns)+` FROM favorites
+		JOIN flats ON flats.id = favorites.flat_id
+		WHERE favorites.user_id = $1 AND flats.status = 'approved' AND flats.availability = 'active'
+		ORDER BY favorites.created_at DESC, flats.id DESC`,
+		userId,
+	)
+	if err != nil {
+		r.log.Error("database: failed to get favorites", slog.Any("error", err))
+		return nil, err
+	}
+	if err = r.attachPhotos(ctx, flats); err != nil {
+		return nil, err
+	}
+	return &flats, nil
+}
+
+// ClaimPriceChangeRecipients returns the users who saved the flat and last
+// saw another price along with that price, and records the new price as
+// seen so every change is sent once.
+func (r *Storage) ClaimPriceChangeRecipients(
+	ctx context.Context, flatId, price int,
+) (*[]structures.PriceChangeRecipient, error) {
+	recipients := make([]structures.PriceChangeRecipient, 0)
+	// the joined old row keeps the price from before the update
+	err := r.db.Select(
+		ctx,
+		&recipients,
+		`UPDATE favorites SET notified_price = $2
+		FROM favorites old JOIN users ON users.id = old.user_id
+		WHERE favorites.flat_id = $1 AND old.flat_id = favorites.flat_id AND old.user_id = favorites.user_id
+			AND old.notified_price <> $2
+		RETURNING users.id AS user_id, users.email, users.delivery, users.locale, old.notified_price AS old_price`,
+		flatId,
+		price,
+	)
+	if err != nil {
+		r.log.Error("database: failed to claim price change recipients", slog.Any("error", err))
+		return nil, err
+	}
+	return &recipients, nil
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/google/uuid"
+)
+
+func (r *Storage) GetPreferences(ctx context.Context, userId uuid.UUID) (*structures.Preferences, error) {
+	var preferences structures.Preferences
+	err := r.db.Get(ctx, &preferences, `SELECT delivery, locale FROM users WHERE id = $1`, userId)
+	if err != nil {
+		r.log.Error("database: failed to get preferences", slog.Any("error", err))
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+// GetRecipient returns the user as a recipient of the emails, it is used
+// for the emails sent regardless of the delivery preference.
+func (r *Storage) GetRecipient(ctx context.Context, userId uuid.UUID) (*structures.Recipient, error) {
+	var recipient structures.Recipient
+	err := r.db.Get(
+		ctx,
+		&recipient,
+		`SELECT id AS user_id, email, delivery, locale FROM users WHERE id = $1`,
+		userId,
+	)
+	if err != nil {
+		r.log.Error("database: failed to get recipient", slog.Any("error", err))
+		return nil, err
+	}
+	return &recipient, nil
+}
+
+// SetDelivery changes the delivery preference of the user, it reports
+// whether the user exists. Muting drops the notifications waiting for a
+// digest.
+func (r *Storage) SetDelivery(ctx context.Context, userId uuid.UUID, delivery string) (bool, error) {
+	tag, err := r.db.Exec(
+		ctx,
+		`WITH muted AS (
+			DELETE FROM pending_notifications WHERE user_id = $1 AND $2 = 'muted'
+		)
+		UPDATE users SET delivery = $2 WHERE id = $1`,
+		userId,
+		delivery,
+	)
+	if err != nil {
+		r.log.Error("database: failed to set delivery", slog.Any("error", err))
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SetLocale changes the locale of the user emails, it reports whether the
+// user exists.
+func (r *Storage) SetLocale(ctx context.Context, userId uuid.UUID, locale string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `UPDATE users SET locale = $2 WHERE id = $1`, userId, locale)
+	if err != nil {
+		r.log.Error("database: failed to set locale", slog.Any("error", err))
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *Storage) SavePendingNotification(ctx context.Context, userId uuid.UUID, message string) error {
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO pending_notifications(user_id, message) VALUES ($1, $2)`,
+		userId,
+		message,
+	)
+	if err != nil {
+		r.log.Error("database: failed to save pending notification", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+// TakePendingNotifications removes and returns the notifications of the users
+// with one of the delivery preferences. Removing them in one statement keeps
+// replicas from sending the same digest twice.
+func (r *Storage) TakePendingNotifications(
+	ctx context.Context, deliveries []string,
+) (*[]structures.PendingNotification, error) {
+	notifications := make([]structures.PendingNotification, 0)
+	err := r.db.Select(
+		ctx,
+		&notifications,
+		`DELETE FROM pending_notifications p USING users u
+		WHERE p.user_id = u.id AND u.delivery = ANY($1)
+		RETURNING p.id, p.user_id, u.email, u.locale, p.message, p.created_at`,
+		deliveries,
+	)
+	if err != nil {
+		r.log.Error("database: failed to take pending notifications", slog.Any("error", err))
+		return nil, err
+	}
+	return &notifications, nil
+}
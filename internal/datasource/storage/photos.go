@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+const photoColumns = "id,flat_id,key,thumbnail_key,url,thumbnail_url,content_type,size,created_at"
+
+func (r *Storage) SaveFlatPhoto(ctx context.Context, photo structures.Photo) (*structures.Photo, error) {
+	var result structures.Photo
+	err := r.db.Get(
+		ctx,
+		&result,
+		`INSERT INTO flat_photos(flat_id, key, thumbnail_key, url, thumbnail_url, content_type, size)
+		VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING `+photoColumns,
+		photo.FlatId,
+		photo.Key,
+		photo.ThumbnailKey,
+		photo.URL,
+		photo.ThumbnailURL,
+		photo.ContentType,
+		photo.Size,
+	)
+	if err != nil {
+		r.log.Error("database: failed to save flat photo", slog.Any("error", err))
+		return nil, err
+	}
+	return &result, nil
+}
+
+// attachPhotos loads the photos of all flats with a single query.
+func (r *Storage) attachPhotos(ctx context.Context, flats []structures.Flat) error {
+	if len(flats) == 0 {
+		return nil
+	}
+	ids := make([]int, len(flats))
+	for i, flat := range flats {
+		ids[i] = flat.Id
+	}
+
+	var photos []structures.Photo
+	err := r.db.Select(
+		ctx,
+		&photos,
+		"SELECT "+photoColumns+" FROM flat_photos WHERE flat_id = ANY($1) ORDER BY id",
+		ids,
+	)
+	if err != nil {
+		r.log.Error("database: failed to get flat photos", slog.Any("error", err))
+		return err
+	}
+
+	byFlat := make(map[int][]structures.Photo, len(flats))
+	for _, photo := range photos {
+		byFlat[photo.FlatId] = append(byFlat[photo.FlatId], photo)
+	}
+	for i := range flats {
+		flats[i].Photos = byFlat[flats[i].Id]
+	}
+	return nil
+}
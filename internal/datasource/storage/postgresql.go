@@ -2,12 +2,19 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/db"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/geohash"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 type Storage struct {
@@ -48,20 +55,36 @@ func (r *Storage) GetUserById(ctx context.Context, id uuid.UUID) (*structures.Us
 	return &a, nil
 }
 
-func (r *Storage) SaveHouse(ctx context.Context, address, developer string, year int) (*structures.House, error) {
-	var house structures.House
-	err := r.db.ExecQueryRow(
+const houseColumns = "id,address,year,developer,created_at,update_at,city,street,building,block,latitude,longitude"
+
+func (r *Storage) SaveHouse(ctx context.Context, house structures.House) (*structures.House, error) {
+	var result structures.House
+	err := r.db.Get(
 		ctx,
-		`INSERT INTO houses(address, developer, year) VALUES($1, $2, $3) RETURNING *`,
-		address,
-		developer,
-		year,
-	).Scan(&house.Id, &house.Address, &house.Year, &house.Developer, &house.CreatedAt, &house.UpdateAt)
+		&result,
+		`INSERT INTO houses(address, developer, year, city, street, building, block, address_normalized,
+			latitude, longitude, geohash)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, '')) RETURNING `+houseColumns,
+		house.Address,
+		house.Developer,
+		house.Year,
+		house.City,
+		house.Street,
+		house.Building,
+		house.Block,
+		house.AddressNormalized,
+		house.Latitude,
+		house.Longitude,
+		house.Geohash,
+	)
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("house %q: %w", house.Address, structures.ErrAlreadyExists)
+	}
 	if err != nil {
 		r.log.Error("database: failed to save house")
 		return nil, err
 	}
-	return &house, err
+	return &result, nil
 }
 
 func (r *Storage) GetHouse(ctx context.Context, id int) (*structures.House, error) {
@@ -69,7 +92,7 @@ func (r *Storage) GetHouse(ctx context.Context, id int) (*structures.House, erro
 	err := r.db.Get(
 		ctx,
 		&house,
-		"SELECT id,address,year,developer,created_at,update_at FROM houses WHERE id=$1",
+		"SELECT "+houseColumns+" FROM houses WHERE id=$1",
 		id,
 	)
 	if err != nil {
@@ -80,21 +103,38 @@ func (r *Storage) GetHouse(ctx context.Context, id int) (*structures.House, erro
 	return &house, nil
 }
 
-func (r *Storage) SaveFlat(ctx context.Context, houseId, price, rooms int) (*structures.Flat, error) {
-	var flat structures.Flat
-	err := r.db.ExecQueryRow(
-		ctx,
-		`INSERT INTO flats(house_id, price, rooms) VALUES($1, $2, $3) RETURNING *`,
-		houseId,
-		price,
-		rooms,
-	).Scan(&flat.Id, &flat.HouseId, &flat.Price, &flat.Rooms, &flat.Status)
+const flatColumns = "id,house_id,price,rooms,status,number,area,floor,description,author_id," +
+	"moderation_reason,priority,duplicate_of,availability,price_dropped,approved_price,moderator_id"
 
+func (r *Storage) SaveFlat(ctx context.Context, flat structures.Flat) (*structures.Flat, error) {
+	var result structures.Flat
+	err := r.db.Get(
+		ctx,
+		&result,
+		`INSERT INTO flats(house_id, price, rooms, number, area, floor, description, author_id,
+			moderation_reason, priority, duplicate_of, status)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING `+flatColumns,
+		flat.HouseId,
+		flat.Price,
+		flat.Rooms,
+		flat.Number,
+		flat.Area,
+		flat.Floor,
+		flat.Description,
+		flat.AuthorId,
+		flat.ModerationReason,
+		flat.Priority,
+		flat.DuplicateOf,
+		flat.Status,
+	)
+	if isUniqueViolation(err) {
+		return nil, flatExists(flat)
+	}
 	if err != nil {
 		r.log.Error("database: failed to save flat")
 		return nil, err
 	}
-	return &flat, nil
+	return &result, nil
 }
 
 func (r *Storage) GetFlat(ctx context.Context, id int) (*structures.Flat, error) {
@@ -102,15 +142,173 @@ func (r *Storage) GetFlat(ctx context.Context, id int) (*structures.Flat, error)
 	err := r.db.Get(
 		ctx,
 		&flat,
-		"SELECT id,house_id,price,rooms,status FROM flats WHERE id=$1", id,
+		"SELECT "+flatColumns+" FROM flats WHERE id=$1", id,
 	)
 	if err != nil {
 		r.log.Error("database: failed to get flat")
 		return nil, err
 	}
+
+	flats := []structures.Flat{flat}
+	if err = r.attachPhotos(ctx, flats); err != nil {
+		return nil, err
+	}
+	return &flats[0], nil
+}
+
+// UpdateFlat replaces the listing details with the pre-moderation decision
+// on them, an edited flat goes through the moderation again and leaves its
+// moderator. A price change is written to the price history in the same
+// statement.
+func (r *Storage) UpdateFlat(ctx context.Context, flat structures.Flat) (*structures.Flat, error) {
+	var result structures.Flat
+	err := r.db.Get(
+		ctx,
+		&result,
+		`WITH old AS (
+			SELECT id, price FROM flats WHERE id = $1 FOR UPDATE
+		), updated AS (
+			UPDATE flats SET price = $2, rooms = $3, number = $4, area = $5, floor = $6, description = $7,
+				status = $8, moderation_reason = $9, priority = $10, duplicate_of = $11, moderator_id = NULL,
+				price_dropped = CASE WHEN old.price <> $2 THEN $2 < old.price ELSE flats.price_dropped END
+			FROM old WHERE flats.id = old.id
+			RETURNING `+prefixColumns("flats", flatColumns)+`, old.price AS old_price
+		), history AS (
+			INSERT INTO flat_price_history(flat_id, old_price, new_price)
+			SELECT id, old_price, price FROM updated WHERE old_price <> price
+		)
+		SELECT `+flatColumns+` FROM updated`,
+		flat.Id,
+		flat.Price,
+		flat.Rooms,
+		flat.Number,
+		flat.Area,
+		flat.Floor,
+		flat.Description,
+		flat.Status,
+		flat.ModerationReason,
+		flat.Priority,
+		flat.DuplicateOf,
+	)
+	if isUniqueViolation(err) {
+		return nil, flatExists(flat)
+	}
+	if err != nil {
+		r.log.Error("database: failed to update flat", slog.Any("error", err))
+		return nil, err
+	}
+
+	flats := []structures.Flat{result}
+	if err = r.attachPhotos(ctx, flats); err != nil {
+		return nil, err
+	}
+	return &flats[0], nil
+}
+
+// UpdateAvailability changes the availability of the flat. It does not touch
+// the update_at of the house, which is the date of the last new flat.
+// UpdateAvailability changes the availability of the flat if it is still
+// the one the change was checked against.
+func (r *Storage) UpdateAvailability(ctx context.Context, id int, from, to string) (*structures.Flat, error) {
+	var flat structures.Flat
+	err := r.db.Get(
+		ctx,
+		&flat,
+		"UPDATE flats SET availability = $3 WHERE id = $1 AND availability = $2 RETURNING "+flatColumns,
+		id,
+		from,
+		to,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, structures.ErrAvailabilityChanged
+	}
+	if err != nil {
+		r.log.Error("database: failed to update availability", slog.Any("error", err))
+		return nil, err
+	}
 	return &flat, nil
 }
 
+// FindDuplicateFlats returns other not declined flats of the same author in
+// the house with the same number, rooms and area and the price in the range.
+func (r *Storage) FindDuplicateFlats(
+	ctx context.Context, flat structures.Flat, priceFrom, priceTo int,
+) (*[]structures.Flat, error) {
+	flats := make([]structures.Flat, 0)
+	err := r.db.Select(
+		ctx,
+		&flats,
+		"SELECT "+flatColumns+` FROM flats
+		WHERE house_id = $1 AND author_id = $2 AND id <> $3 AND rooms = $4 AND price BETWEEN $5 AND $6
+			AND number IS NOT DISTINCT FROM $7 AND area IS NOT DISTINCT FROM $8 AND status <> 'declined'
+		ORDER BY id LIMIT 10`,
+		flat.HouseId,
+		flat.AuthorId,
+		flat.Id,
+		flat.Rooms,
+		priceFrom,
+		priceTo,
+		flat.Number,
+		flat.Area,
+	)
+	if err != nil {
+		r.log.Error("database: failed to find duplicate flats", slog.Any("error", err))
+		return nil, err
+	}
+	return &flats, nil
+}
+
+// GetDuplicateClusters returns clusters of duplicates ordered by the id of
+// the original flat, starting after the given id.
+func (r *Storage) GetDuplicateClusters(
+	ctx context.Context, afterId, limit int,
+) (*[]structures.DuplicateCluster, error) {
+	var roots []int
+	err := r.db.Select(
+		ctx,
+		&roots,
+		`SELECT DISTINCT duplicate_of FROM flats WHERE duplicate_of > $1 ORDER BY duplicate_of LIMIT $2`,
+		afterId,
+		limit,
+	)
+	if err != nil {
+		r.log.Error("database: failed to get duplicate clusters", slog.Any("error", err))
+		return nil, err
+	}
+
+	clusters := make([]structures.DuplicateCluster, 0, len(roots))
+	if len(roots) == 0 {
+		return &clusters, nil
+	}
+
+	var flats []structures.Flat
+	err = r.db.Select(
+		ctx,
+		&flats,
+		"SELECT "+flatColumns+" FROM flats WHERE id = ANY($1) OR duplicate_of = ANY($1) ORDER BY id",
+		roots,
+	)
+	if err != nil {
+		r.log.Error("database: failed to get duplicate flats", slog.Any("error", err))
+		return nil, err
+	}
+
+	index := make(map[int]int, len(roots))
+	for i, root := range roots {
+		index[root] = i
+		clusters = append(clusters, structures.DuplicateCluster{Original: structures.Flat{Id: root}})
+	}
+	for _, flat := range flats {
+		if flat.DuplicateOf == nil {
+			clusters[index[flat.Id]].Original = flat
+			continue
+		}
+		cluster := &clusters[index[*flat.DuplicateOf]]
+		cluster.Duplicates = append(cluster.Duplicates, flat)
+	}
+	return &clusters, nil
+}
+
 func (r *Storage) UpdateDate(ctx context.Context, time time.Time, id int) error {
 	_, err := r.db.Exec(
 		ctx,
@@ -125,72 +323,328 @@ func (r *Storage) UpdateDate(ctx context.Context, time time.Time, id int) error
 	return nil
 }
 
-func (r *Storage) UpdateStatus(ctx context.Context, id int, status string) error {
-	_, err := r.db.Exec(
-		ctx,
-		"UPDATE flats SET status = $1 WHERE id = $2",
-		status,
-		id,
-	)
+var flatSortColumns = map[string]string{
+	"id":    "id",
+	"price": "price",
+	"rooms": "rooms",
+}
+
+func (r *Storage) GetListByClient(ctx context.Context, id int, filter structures.FlatFilter) (*[]structures.Flat, error) {
+	filter.Status = "approved"
+	filter.ActiveOnly = true
+	flats, err := r.getList(ctx, id, filter)
 	if err != nil {
-		r.log.Error("database: failed to update status")
-		return err
+		r.log.Error("database: failed to get list by client", slog.Any("error", err))
+		return nil, err
 	}
-	return nil
+	return flats, nil
 }
 
-func (r *Storage) GetListByClient(ctx context.Context, id int) (*[]structures.Flat, error) {
-	status := "approved"
-	rows, err := r.db.Query(
+func (r *Storage) GetListByModerator(ctx context.Context, id int, filter structures.FlatFilter) (*[]structures.Flat, error) {
+	r.log.Info("database start")
+	flats, err := r.getList(ctx, id, filter)
+	if err != nil {
+		r.log.Error("database: failed to get list by moderator", slog.Any("error", err))
+		return nil, err
+	}
+	r.log.Info("database end")
+	return flats, nil
+}
+
+func (r *Storage) getList(ctx context.Context, houseId int, filter structures.FlatFilter) (*[]structures.Flat, error) {
+	var q query
+
+	q.and("house_id = " + q.arg(houseId))
+	if filter.Status != "" {
+		q.and("status = " + q.arg(filter.Status))
+	}
+	if filter.ActiveOnly {
+		q.and("availability = 'active'")
+	}
+	if filter.Priority {
+		q.and("priority")
+	}
+	if filter.PriceFrom > 0 {
+		q.and("price >= " + q.arg(filter.PriceFrom))
+	}
+	if filter.PriceTo > 0 {
+		q.and("price <= " + q.arg(filter.PriceTo))
+	}
+	if filter.RoomsFrom > 0 {
+		q.and("rooms >= " + q.arg(filter.RoomsFrom))
+	}
+	if filter.RoomsTo > 0 {
+		q.and("rooms <= " + q.arg(filter.RoomsTo))
+	}
+
+	column, ok := flatSortColumns[filter.SortBy]
+	if !ok {
+		column = flatSortColumns["id"]
+	}
+	if filter.After != nil {
+		q.keyset(column, "id", "int", filter.Desc, filter.After.Value, filter.After.Id)
+	}
+
+	flats := make([]structures.Flat, 0, filter.Limit)
+	err := r.db.Select(
 		ctx,
-		"SELECT id,house_id,price,rooms,status FROM flats WHERE house_id=$1 AND status=$2", id, status,
+		&flats,
+		"SELECT "+flatColumns+" FROM flats"+q.whereClause()+orderBy(column, "id", filter.Desc)+" LIMIT "+q.arg(filter.Limit),
+		q.args...,
 	)
 	if err != nil {
-		r.log.Error("database: failed to get list by client", err)
 		return nil, err
 	}
-	defer rows.Close()
+	if err = r.attachPhotos(ctx, flats); err != nil {
+		return nil, err
+	}
+	return &flats, nil
+}
 
-	var flats []structures.Flat
-	for rows.Next() {
-		var flat structures.Flat
-		if err := rows.Scan(&flat.Id, &flat.HouseId, &flat.Price, &flat.Rooms, &flat.Status); err != nil {
-			return &flats, err
-		}
-		flats = append(flats, flat)
+var houseSortColumns = map[string]string{
+	"created_at": "h.created_at",
+	"update_at":  "h.update_at",
+}
+
+func (r *Storage) GetHouses(ctx context.Context, filter structures.HouseFilter) (*[]structures.House, error) {
+	var q query
+
+	if filter.Address != "" {
+		q.and(fmt.Sprintf(`h.address ILIKE '%%' || %s || '%%'`, q.arg(escapeLike(filter.Address))))
+	}
+	if filter.Developer != "" {
+		q.and("h.developer = " + q.arg(filter.Developer))
+	}
+	if filter.YearFrom > 0 {
+		q.and("h.year >= " + q.arg(filter.YearFrom))
+	}
+	if filter.YearTo > 0 {
+		q.and("h.year <= " + q.arg(filter.YearTo))
+	}
+	if filter.HasApproved {
+		q.and("EXISTS (SELECT 1 FROM flats f WHERE f.house_id = h.id AND f.status = 'approved' AND f.availability = 'active')")
+	}
+
+	column, ok := houseSortColumns[filter.SortBy]
+	if !ok {
+		column = houseSortColumns["created_at"]
 	}
-	if err = rows.Err(); err != nil {
-		r.log.Error("database: failed to get list by client", err)
-		return &flats, err
+	if filter.After != nil {
+		q.keyset(column, "h.id", "timestamptz", filter.Desc, filter.After.Value, filter.After.Id)
+	}
+
+	sql := "SELECT " + houseColumns + " FROM houses h" +
+		q.whereClause() + orderBy(column, "h.id", filter.Desc) + " LIMIT " + q.arg(filter.Limit)
+
+	houses := make([]structures.House, 0, filter.Limit)
+	if err := r.db.Select(ctx, &houses, sql, q.args...); err != nil {
+		r.log.Error("database: failed to get list of houses", slog.Any("error", err))
+		return nil, err
+	}
+	return &houses, nil
+}
+
+func (r *Storage) SearchFlats(ctx context.Context, filter structures.FlatSearchFilter) (*[]structures.FlatSearchResult, error) {
+	q := searchFlatsQuery(filter)
+
+	column, ok := flatSortColumns[filter.SortBy]
+	if !ok {
+		column = flatSortColumns["id"]
+	}
+	column = "f." + column
+	if filter.After != nil {
+		q.keyset(column, "f.id", "int", filter.Desc, filter.After.Value, filter.After.Id)
+	}
+
+	sql := "SELECT " + prefixColumns("f", flatColumns) + ",h.address,h.year,h.developer" +
+		" FROM flats f JOIN houses h ON h.id = f.house_id" +
+		q.whereClause() + orderBy(column, "f.id", filter.Desc) + " LIMIT " + q.arg(filter.Limit)
+
+	flats := make([]structures.FlatSearchResult, 0, filter.Limit)
+	if err := r.db.Select(ctx, &flats, sql, q.args...); err != nil {
+		r.log.Error("database: failed to search flats", slog.Any("error", err))
+		return nil, err
+	}
+
+	list := make([]structures.Flat, len(flats))
+	for i := range flats {
+		list[i] = flats[i].Flat
+	}
+	if err := r.attachPhotos(ctx, list); err != nil {
+		return nil, err
+	}
+	for i := range flats {
+		flats[i].Photos = list[i].Photos
 	}
 	return &flats, nil
 }
 
-func (r *Storage) GetListByModerator(ctx context.Context, id int) (*[]structures.Flat, error) {
-	r.log.Info("database start")
-	rows, err := r.db.Query(
+// EstimateSearchFlats returns the planner estimate of the number of flats
+// matching the filter, an exact count is too slow on the whole flats table.
+func (r *Storage) EstimateSearchFlats(ctx context.Context, filter structures.FlatSearchFilter) (int64, error) {
+	q := searchFlatsQuery(filter)
+
+	var plan []byte
+	err := r.db.ExecQueryRow(
 		ctx,
-		"SELECT id,house_id,price,rooms,status FROM flats WHERE house_id=$1", id,
+		"EXPLAIN (FORMAT JSON) SELECT 1 FROM flats f JOIN houses h ON h.id = f.house_id"+q.whereClause(),
+		q.args...,
+	).Scan(&plan)
+	if err != nil {
+		r.log.Error("database: failed to estimate flats", slog.Any("error", err))
+		return 0, err
+	}
+
+	var explain []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err = json.Unmarshal(plan, &explain); err != nil || len(explain) == 0 {
+		r.log.Error("database: failed to parse flats estimate", slog.Any("error", err))
+		return 0, fmt.Errorf("failed to parse query plan: %w", err)
+	}
+	return int64(explain[0].Plan.Rows), nil
+}
+
+func searchFlatsQuery(filter structures.FlatSearchFilter) query {
+	var q query
+
+	q.and("f.status = 'approved'")
+	q.and("f.availability = 'active'")
+	if filter.PriceFrom > 0 {
+		q.and("f.price >= " + q.arg(filter.PriceFrom))
+	}
+	if filter.PriceTo > 0 {
+		q.and("f.price <= " + q.arg(filter.PriceTo))
+	}
+	if filter.RoomsFrom > 0 {
+		q.and("f.rooms >= " + q.arg(filter.RoomsFrom))
+	}
+	if filter.RoomsTo > 0 {
+		q.and("f.rooms <= " + q.arg(filter.RoomsTo))
+	}
+	if filter.YearFrom > 0 {
+		q.and("h.year >= " + q.arg(filter.YearFrom))
+	}
+	if filter.YearTo > 0 {
+		q.and("h.year <= " + q.arg(filter.YearTo))
+	}
+	if filter.Developer != "" {
+		q.and("h.developer = " + q.arg(filter.Developer))
+	}
+	if filter.Address != "" {
+		q.and(fmt.Sprintf(`h.address ILIKE '%%' || %s || '%%'`, q.arg(escapeLike(filter.Address))))
+	}
+	return q
+}
+
+// SuggestAddresses completes a partial address: words are matched as
+// prefixes against the address tsvector, if nothing matches the trigram
+// similarity is used to tolerate typos.
+func (r *Storage) SuggestAddresses(ctx context.Context, text string, limit int) (*[]structures.AddressSuggestion, error) {
+	suggestions := make([]structures.AddressSuggestion, 0, limit)
+
+	if tsQuery := prefixTsQuery(text); tsQuery != "" {
+		err := r.db.Select(
+			ctx,
+			&suggestions,
+			`SELECT id,address FROM houses WHERE address_tsv @@ to_tsquery('russian', $1)
+			ORDER BY ts_rank(address_tsv, to_tsquery('russian', $1)) DESC, id LIMIT $2`,
+			tsQuery,
+			limit,
+		)
+		if err != nil {
+			r.log.Error("database: failed to suggest addresses", slog.Any("error", err))
+			return nil, err
+		}
+		if len(suggestions) > 0 {
+			return &suggestions, nil
+		}
+	}
+
+	err := r.db.Select(
+		ctx,
+		&suggestions,
+		`SELECT id,address FROM houses WHERE address % $1
+		ORDER BY similarity(address, $1) DESC, id LIMIT $2`,
+		text,
+		limit,
 	)
 	if err != nil {
-		r.log.Error("database: failed to get list by client", err)
+		r.log.Error("database: failed to suggest addresses by similarity", slog.Any("error", err))
 		return nil, err
 	}
-	defer rows.Close()
+	return &suggestions, nil
+}
 
-	var flats []structures.Flat
-	for rows.Next() {
-		var flat structures.Flat
-		if err := rows.Scan(&flat.Id, &flat.HouseId, &flat.Price, &flat.Rooms, &flat.Status); err != nil {
-			r.log.Error("database: failed to get list by client", err)
-			return &flats, err
-		}
-		flats = append(flats, flat)
+// prefixTsQuery turns free text into a tsquery where every word is a prefix.
+func prefixTsQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
 	}
-	if err = rows.Err(); err != nil {
-		r.log.Error("database: failed to get list by client", err)
-		return &flats, err
+	return strings.Join(words, " & ")
+}
+
+// distanceSQL is the haversine distance in meters from the point given by
+// the first two arguments of the query.
+const distanceSQL = `6371000 * 2 * asin(sqrt(
+	power(sin(radians(latitude - $1) / 2), 2) +
+	cos(radians($1)) * cos(radians(latitude)) * power(sin(radians(longitude - $2) / 2), 2)))`
+
+// GetHousesNearby returns houses within the radius in meters ordered by
+// distance, cells are the geohash prefixes covering the circle.
+func (r *Storage) GetHousesNearby(
+	ctx context.Context, lat, lon, radius float64, cells []string, limit int,
+) (*[]structures.HouseDistance, error) {
+	var q query
+	q.arg(lat)
+	q.arg(lon)
+	box := geohash.RadiusBox(lat, lon, radius)
+	geoConditions(&q, box, cells)
+
+	sql := "SELECT * FROM (SELECT " + houseColumns + "," + distanceSQL + " AS distance FROM houses" +
+		q.whereClause() + ") h WHERE distance <= " + q.arg(radius) + " ORDER BY distance, id LIMIT " + q.arg(limit)
+
+	houses := make([]structures.HouseDistance, 0, limit)
+	if err := r.db.Select(ctx, &houses, sql, q.args...); err != nil {
+		r.log.Error("database: failed to get houses nearby", slog.Any("error", err))
+		return nil, err
 	}
-	r.log.Info("database end")
-	return &flats, nil
+	return &houses, nil
+}
+
+// GetHousesInBox returns houses inside the box, cells are the geohash
+// prefixes covering it.
+func (r *Storage) GetHousesInBox(
+	ctx context.Context, box geohash.Box, cells []string, limit int,
+) (*[]structures.House, error) {
+	var q query
+	geoConditions(&q, box, cells)
+
+	sql := "SELECT " + houseColumns + " FROM houses" + q.whereClause() + " ORDER BY id LIMIT " + q.arg(limit)
+
+	houses := make([]structures.House, 0, limit)
+	if err := r.db.Select(ctx, &houses, sql, q.args...); err != nil {
+		r.log.Error("database: failed to get houses in box", slog.Any("error", err))
+		return nil, err
+	}
+	return &houses, nil
+}
+
+// geoConditions narrows the rows by geohash prefixes, which are served by
+// the geohash index, and then by the exact box.
+func geoConditions(q *query, box geohash.Box, cells []string) {
+	prefixes := make([]string, 0, len(cells))
+	for _, cell := range cells {
+		prefixes = append(prefixes, "geohash LIKE "+q.arg(cell+"%"))
+	}
+	if len(prefixes) > 0 {
+		q.and("(" + strings.Join(prefixes, " OR ") + ")")
+	}
+	q.and(fmt.Sprintf("latitude BETWEEN %s AND %s", q.arg(box.MinLat), q.arg(box.MaxLat)))
+	q.and(fmt.Sprintf("longitude BETWEEN %s AND %s", q.arg(box.MinLon), q.arg(box.MaxLon)))
 }
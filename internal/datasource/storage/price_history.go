@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+func (r *Storage) GetPriceHistory(ctx context.Context, flatId int) (*[]structures.PriceChange, error) {
+	history := make([]structures.PriceChange, 0)
+	err := r.db.Select(
+		ctx,
+		&history,
+		`SELECT id,flat_id,old_price,new_price,changed_at FROM flat_price_history
+		WHERE flat_id = $1 ORDER BY changed_at, id`,
+		flatId,
+	)
+	if err != nil {
+		r.log.Error("database: failed to get price history", slog.Any("error", err))
+		return nil, err
+	}
+	return &history, nil
+}
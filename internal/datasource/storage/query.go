@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// query collects WHERE conditions and numbered arguments for the dynamic
+// list queries.
+type query struct {
+	where []string
+	args  []interface{}
+}
+
+// arg registers a value and returns its placeholder.
+func (q *query) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *query) and(cond string) {
+	q.where = append(q.where, cond)
+}
+
+func (q *query) whereClause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+// keyset adds the (column, id) condition used to continue after a cursor.
+func (q *query) keyset(column, idColumn, cast string, desc bool, value string, id int) {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	if column == idColumn {
+		q.and(fmt.Sprintf("%s %s %s", idColumn, op, q.arg(id)))
+		return
+	}
+	q.and(fmt.Sprintf("(%s, %s) %s (%s::%s, %s)", column, idColumn, op, q.arg(value), cast, q.arg(id)))
+}
+
+func orderBy(column, idColumn string, desc bool) string {
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	if column == idColumn {
+		return fmt.Sprintf(" ORDER BY %s %s", idColumn, direction)
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s", column, direction, idColumn, direction)
+}
+
+// escapeLike escapes the LIKE wildcards in user input.
+func escapeLike(str string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(str)
+}
+
+// prefixColumns qualifies every column of the list with the table alias.
+func prefixColumns(alias, columns string) string {
+	list := strings.Split(columns, ",")
+	for i, column := range list {
+		list[i] = alias + "." + column
+	}
+	return strings.Join(list, ",")
+}
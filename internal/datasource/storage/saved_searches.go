@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/google/uuid"
+)
+
+const savedSearchColumns = "id,user_id,price_from,price_to,rooms_from,rooms_to,developer,address,created_at"
+
+func (r *Storage) SaveSearch(ctx context.Context, search structures.SavedSearch) (*structures.SavedSearch, error) {
+	var result structures.SavedSearch
+	err := r.db.Get(
+		ctx,
+		&result,
+		`INSERT INTO saved_searches(user_id, price_from, price_to, rooms_from, rooms_to, developer, address)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING `+savedSearchColumns,
+		search.UserId,
+		search.PriceFrom,
+		search.PriceTo,
+		search.RoomsFrom,
+		search.RoomsTo,
+		search.Developer,
+		search.Address,
+	)
+	if err != nil {
+		r.log.Error("database: failed to save search", slog.Any("error", err))
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *Storage) GetSavedSearches(ctx context.Context, userId uuid.UUID) (*[]structures.SavedSearch, error) {
+	searches := make([]structures.SavedSearch, 0)
+	err := r.db.Select(
+		ctx,
+		&searches,
+		`SELECT `+savedSearchColumns+` FROM saved_searches WHERE user_id = $1 ORDER BY id`,
+		userId,
+	)
+	if err != nil {
+		r.log.Error("database: failed to get saved searches", slog.Any("error", err))
+		return nil, err
+	}
+	return &searches, nil
+}
+
+// DeleteSavedSearch removes the search of the user, it reports whether the
+// search existed.
+func (r *Storage) DeleteSavedSearch(ctx context.Context, userId uuid.UUID, id int) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM saved_searches WHERE id = $1 AND user_id = $2`, id, userId)
+	if err != nil {
+		r.log.Error("database: failed to delete saved search", slog.Any("error", err))
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// FindSearchMatches returns the saved searches matched by the flats, only
+// approved and active flats are considered.
+func (r *Storage) FindSearchMatches(ctx context.Context, flatIds []int) (*[]structures.SearchMatch, error) {
+	matches := make([]structures.SearchMatch, 0)
+	err := r.db.Select(
+		ctx,
+		&matches,
+		`SELECT s.id AS search_id, u.id AS user_id, u.email, u.delivery, u.locale, f.id AS flat_id, f.price, f.rooms, h.address
+		FROM flats f
+		JOIN houses h ON h.id = f.house_id
+		JOIN saved_searches s ON (s.price_from = 0 OR f.price >= s.price_from)
+			AND (s.price_to = 0 OR f.price <= s.price_to)
+			AND (s.rooms_from = 0 OR f.rooms >= s.rooms_from)
+			AND (s.rooms_to = 0 OR f.rooms <= s.rooms_to)
+			AND (s.developer = '' OR h.developer = s.developer)
+			AND (s.address = '' OR strpos(lower(h.address), lower(s.address)) > 0)
+		JOIN users u ON u.id = s.user_id
+		WHERE f.id = ANY($1) AND f.status = 'approved' AND f.availability = 'active'
+		ORDER BY u.id, f.id`,
+		flatIds,
+	)
+	if err != nil {
+		r.log.Error("database: failed to find search matches", slog.Any("error", err))
+		return nil, err
+	}
+	return &matches, nil
+}
+
+// QueueSearchAlert adds the approved flat to the next batch of the saved
+// search alerts, a flat already waiting is queued once.
+func (r *Storage) QueueSearchAlert(ctx context.Context, flatId int) error {
+	_, err := r.db.Exec(
+		ctx, `INSERT INTO search_alert_queue(flat_id) VALUES ($1) ON CONFLICT DO NOTHING`, flatId,
+	)
+	if err != nil {
+		r.log.Error("database: failed to queue search alert", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+// ClaimSearchAlerts returns the queued flats leased for the batch, the flats
+// of a replica which failed to send the batch are claimed again after the
+// lease.
+func (r *Storage) ClaimSearchAlerts(ctx context.Context, limit int, lease time.Duration) (*[]int, error) {
+	flatIds := make([]int, 0, limit)
+	err := r.db.Select(
+		ctx,
+		&flatIds,
+		`WITH due AS (
+			SELECT flat_id FROM search_alert_queue
+			WHERE claimed_until <= NOW()
+			ORDER BY created_at LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE search_alert_queue q SET claimed_until = NOW() + make_interval(secs => $2)
+		FROM due WHERE q.flat_id = due.flat_id
+		RETURNING q.flat_id`,
+		limit,
+		lease.Seconds(),
+	)
+	if err != nil {
+		r.log.Error("database: failed to claim search alerts", slog.Any("error", err))
+		return nil, err
+	}
+	return &flatIds, nil
+}
+
+// DeleteSearchAlerts removes the flats of a sent batch from the queue.
+func (r *Storage) DeleteSearchAlerts(ctx context.Context, flatIds []int) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM search_alert_queue WHERE flat_id = ANY($1)`, flatIds)
+	if err != nil {
+		r.log.Error("database: failed to delete search alerts", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+package structures
+
+// Cursor points at the last row of a page for keyset pagination:
+// Value holds the sort column value, Id breaks ties between equal values.
+type Cursor struct {
+	Value string `json:"v"`
+	Id    int    `json:"id"`
+}
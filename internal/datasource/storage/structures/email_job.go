@@ -0,0 +1,34 @@
+package structures
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Email job statuses, a dead job ran out of attempts.
+const (
+	JobPending = "pending"
+	JobSent    = "sent"
+	JobDead    = "dead"
+)
+
+type EmailJob struct {
+	Id             int       `db:"id"`
+	IdempotencyKey string    `db:"idempotency_key"`
+	Recipient      string    `db:"recipient"`
+	Message        string    `db:"message"`
+	Status         string    `db:"status"`
+	Attempts       int       `db:"attempts"`
+	NextAttemptAt  time.Time `db:"next_attempt_at"`
+	LastError      string    `db:"last_error"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+	// LeaseToken identifies the claim the job was taken with
+	LeaseToken uuid.UUID `db:"lease_token"`
+}
+
+type EmailJobCount struct {
+	Status string `db:"status" json:"status"`
+	Count  int64  `db:"count" json:"count"`
+}
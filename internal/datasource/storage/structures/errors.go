@@ -0,0 +1,13 @@
+package structures
+
+import "errors"
+
+var (
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrNotClaimable is returned when the flat is not waiting for a
+	// moderator or is claimed by another one.
+	ErrNotClaimable = errors.New("flat is not claimable")
+	// ErrAvailabilityChanged is returned when the availability of the flat
+	// was changed by another request meanwhile.
+	ErrAvailabilityChanged = errors.New("flat availability has changed")
+)
@@ -1,9 +1,76 @@
 package structures
 
+import "github.com/google/uuid"
+
 type Flat struct {
-	Id      int    `db:"id" json:"id,omitempty"`
-	HouseId int    `db:"house_id" json:"house_id,omitempty"`
-	Price   int    `db:"price" json:"price,omitempty"`
-	Rooms   int    `db:"rooms" json:"rooms,omitempty"`
-	Status  string `db:"status" json:"status,omitempty"`
+	Id          int      `db:"id" json:"id,omitempty"`
+	HouseId     int      `db:"house_id" json:"house_id,omitempty"`
+	Price       int      `db:"price" json:"price,omitempty"`
+	Rooms       int      `db:"rooms" json:"rooms,omitempty"`
+	Status      string   `db:"status" json:"status,omitempty"`
+	Number      *int     `db:"number" json:"number,omitempty"`
+	Area        *float64 `db:"area" json:"area,omitempty"`
+	Floor       *int     `db:"floor" json:"floor,omitempty"`
+	Description string   `db:"description" json:"description,omitempty"`
+	// AuthorId is empty for flats created before authors were stored.
+	AuthorId *uuid.UUID `db:"author_id" json:"-"`
+	// ModerationReason and Priority are set by the automatic pre-moderation.
+	ModerationReason string `db:"moderation_reason" json:"moderation_reason,omitempty"`
+	Priority         bool   `db:"priority" json:"priority,omitempty"`
+	// Availability is controlled by the author independently of the moderation.
+	Availability string `db:"availability" json:"availability,omitempty"`
+	// PriceDropped is set when the last price change was a reduction.
+	PriceDropped bool `db:"price_dropped" json:"price_dropped"`
+	// ApprovedPrice is the price of the last approval, it is empty for a
+	// flat never approved.
+	ApprovedPrice *int `db:"approved_price" json:"-"`
+	// ModeratorId is the moderator who claimed the flat for the review.
+	ModeratorId *uuid.UUID `db:"moderator_id" json:"-"`
+	// DuplicateOf is the first flat of the cluster of likely duplicates.
+	DuplicateOf *int    `db:"duplicate_of" json:"duplicate_of,omitempty"`
+	Photos      []Photo `db:"-" json:"photos,omitempty"`
+}
+
+type FlatFilter struct {
+	PriceFrom int
+	PriceTo   int
+	RoomsFrom int
+	RoomsTo   int
+	Status    string
+	// ActiveOnly hides sold and withdrawn flats.
+	ActiveOnly bool
+	Priority   bool
+	SortBy     string
+	Desc       bool
+	Limit      int
+	After      *Cursor
+}
+
+// Unfiltered reports whether the filter selects the first page of the
+// default listing of a house.
+func (f FlatFilter) Unfiltered() bool {
+	return f.PriceFrom == 0 && f.PriceTo == 0 && f.RoomsFrom == 0 && f.RoomsTo == 0 &&
+		f.Status == "" && !f.Priority && (f.SortBy == "" || f.SortBy == "id") && !f.Desc && f.After == nil
+}
+
+// FlatSearchFilter selects approved flats across houses.
+type FlatSearchFilter struct {
+	FlatFilter
+	YearFrom  int
+	YearTo    int
+	Developer string
+	Address   string
+}
+
+type FlatSearchResult struct {
+	Flat
+	Address   string `db:"address" json:"address"`
+	Year      int    `db:"year" json:"year"`
+	Developer string `db:"developer" json:"developer"`
+}
+
+// DuplicateCluster is a flat with the listings detected as its duplicates.
+type DuplicateCluster struct {
+	Original   Flat   `json:"original"`
+	Duplicates []Flat `json:"duplicates"`
 }
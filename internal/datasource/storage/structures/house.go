@@ -9,4 +9,45 @@ type House struct {
 	Developer string    `db:"developer" json:"developer"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdateAt  time.Time `db:"update_at" json:"update_at"`
+	City      string    `db:"city" json:"city,omitempty"`
+	Street    string    `db:"street" json:"street,omitempty"`
+	Building  string    `db:"building" json:"building,omitempty"`
+	Block     string    `db:"block" json:"block,omitempty"`
+	Latitude  *float64  `db:"latitude" json:"latitude,omitempty"`
+	Longitude *float64  `db:"longitude" json:"longitude,omitempty"`
+	// AddressNormalized and Geohash are written on save and never selected.
+	AddressNormalized string `db:"address_normalized" json:"-"`
+	Geohash           string `db:"geohash" json:"-"`
+}
+
+type HouseDistance struct {
+	House
+	Distance float64 `db:"distance" json:"distance"`
+}
+
+type HouseFilter struct {
+	Address     string
+	Developer   string
+	YearFrom    int
+	YearTo      int
+	HasApproved bool
+	SortBy      string
+	Desc        bool
+	Limit       int
+	After       *Cursor
+}
+
+type AddressSuggestion struct {
+	HouseId int    `db:"id" json:"house_id"`
+	Address string `db:"address" json:"address"`
+}
+
+// Address is the structured form of a house address, Normalized is the
+// key used to detect duplicate houses.
+type Address struct {
+	City       string
+	Street     string
+	Building   string
+	Block      string
+	Normalized string
 }
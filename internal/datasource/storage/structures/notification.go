@@ -0,0 +1,47 @@
+package structures
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Delivery preferences of the user notifications.
+const (
+	DeliveryInstant = "instant"
+	DeliveryHourly  = "hourly"
+	DeliveryDaily   = "daily"
+	DeliveryMuted   = "muted"
+)
+
+// Recipient is a user to be notified with the delivery preference and the
+// locale of the emails.
+type Recipient struct {
+	UserId   uuid.UUID `db:"user_id"`
+	Email    string    `db:"email"`
+	Delivery string    `db:"delivery"`
+	Locale   string    `db:"locale"`
+}
+
+// PriceChangeRecipient is a user who saved the flat and the price they
+// were told about last.
+type PriceChangeRecipient struct {
+	Recipient
+	OldPrice int `db:"old_price"`
+}
+
+// Preferences are the notification settings of the user.
+type Preferences struct {
+	Delivery string `db:"delivery" json:"delivery"`
+	Locale   string `db:"locale" json:"locale"`
+}
+
+// PendingNotification waits for the digest of the user.
+type PendingNotification struct {
+	Id        int       `db:"id"`
+	UserId    uuid.UUID `db:"user_id"`
+	Email     string    `db:"email"`
+	Locale    string    `db:"locale"`
+	Message   string    `db:"message"`
+	CreatedAt time.Time `db:"created_at"`
+}
@@ -0,0 +1,15 @@
+package structures
+
+import "time"
+
+type Photo struct {
+	Id           int       `db:"id" json:"id"`
+	FlatId       int       `db:"flat_id" json:"-"`
+	Key          string    `db:"key" json:"-"`
+	ThumbnailKey string    `db:"thumbnail_key" json:"-"`
+	URL          string    `db:"url" json:"url"`
+	ThumbnailURL string    `db:"thumbnail_url" json:"thumbnail_url"`
+	ContentType  string    `db:"content_type" json:"content_type"`
+	Size         int64     `db:"size" json:"size"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
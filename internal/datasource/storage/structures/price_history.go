@@ -0,0 +1,11 @@
+package structures
+
+import "time"
+
+type PriceChange struct {
+	Id        int       `db:"id" json:"id"`
+	FlatId    int       `db:"flat_id" json:"flat_id"`
+	OldPrice  int       `db:"old_price" json:"old_price"`
+	NewPrice  int       `db:"new_price" json:"new_price"`
+	ChangedAt time.Time `db:"changed_at" json:"changed_at"`
+}
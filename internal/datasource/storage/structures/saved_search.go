@@ -0,0 +1,31 @@
+package structures
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SavedSearch is the criteria a user wants to be alerted about, zero values
+// mean no restriction.
+type SavedSearch struct {
+	Id        int       `db:"id" json:"id"`
+	UserId    uuid.UUID `db:"user_id" json:"-"`
+	PriceFrom int       `db:"price_from" json:"price_from,omitempty"`
+	PriceTo   int       `db:"price_to" json:"price_to,omitempty"`
+	RoomsFrom int       `db:"rooms_from" json:"rooms_from,omitempty"`
+	RoomsTo   int       `db:"rooms_to" json:"rooms_to,omitempty"`
+	Developer string    `db:"developer" json:"developer,omitempty"`
+	Address   string    `db:"address" json:"address,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// SearchMatch is an approved flat matching a saved search of the user.
+type SearchMatch struct {
+	Recipient
+	SearchId int    `db:"search_id"`
+	FlatId   int    `db:"flat_id"`
+	Price    int    `db:"price"`
+	Rooms    int    `db:"rooms"`
+	Address  string `db:"address"`
+}
@@ -0,0 +1,40 @@
+package structures
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook delivery statuses, a dead delivery is not retried anymore.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+type Webhook struct {
+	Id        int       `db:"id" json:"id"`
+	OwnerId   uuid.UUID `db:"owner_id" json:"-"`
+	Url       string    `db:"url" json:"url"`
+	Events    []string  `db:"events" json:"events"`
+	Secret    string    `db:"secret" json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	Id            int       `db:"id" json:"id"`
+	WebhookId     int       `db:"webhook_id" json:"webhook_id"`
+	Event         string    `db:"event" json:"event"`
+	Payload       string    `db:"payload" json:"payload"`
+	Status        string    `db:"status" json:"status"`
+	Attempts      int       `db:"attempts" json:"attempts"`
+	NextAttemptAt time.Time `db:"next_attempt_at" json:"next_attempt_at"`
+	ResponseCode  *int      `db:"response_code" json:"response_code,omitempty"`
+	LastError     string    `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+	// Url and Secret of the webhook, set for the claimed deliveries
+	Url    string `db:"url" json:"-"`
+	Secret string `db:"secret" json:"-"`
+}
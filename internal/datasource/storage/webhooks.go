@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookColumns  = "id,owner_id,url,events,secret,created_at"
+	deliveryColumns = "id,webhook_id,event,payload,status,attempts,next_attempt_at,response_code,last_error," +
+		"created_at,updated_at"
+)
+
+func (r *Storage) SaveWebhook(ctx context.Context, webhook structures.Webhook) (*structures.Webhook, error) {
+	var result structures.Webhook
+	err := r.db.Get(
+		ctx,
+		&result,
+		`INSERT INTO webhooks(owner_id, url, events, secret) VALUES ($1, $2, $3, $4) RETURNING `+webhookColumns,
+		webhook.OwnerId,
+		webhook.Url,
+		webhook.Events,
+		webhook.Secret,
+	)
+	if err != nil {
+		r.log.Error("database: failed to save webhook", slog.Any("error", err))
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *Storage) GetWebhook(ctx context.Context, id int) (*structures.Webhook, error) {
+	var webhook structures.Webhook
+	err := r.db.Get(ctx, &webhook, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		r.log.Error("database: failed to get webhook", slog.Any("error", err))
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *Storage) GetWebhooks(ctx context.Context, ownerId uuid.UUID) (*[]structures.Webhook, error) {
+	webhooks := make([]structures.Webhook, 0)
+	err := r.db.Select(
+		ctx,
+		&webhooks,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE owner_id = $1 ORDER BY id`,
+		ownerId,
+	)
+	if err != nil {
+		r.log.Error("database: failed to get webhooks", slog.Any("error", err))
+		return nil, err
+	}
+	return &webhooks, nil
+}
+
+// DeleteWebhook removes the webhook with its delivery log, it reports
+// whether the webhook existed.
+func (r *Storage) DeleteWebhook(ctx context.Context, ownerId uuid.UUID, id int) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM webhooks WHERE id = $1 AND owner_id = $2`, id, ownerId)
+	if err != nil {
+		r.log.Error("database: failed to delete webhook", slog.Any("error", err))
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// EnqueueWebhookDeliveries creates a pending delivery of the event for every
+// webhook subscribed to it.
+func (r *Storage) EnqueueWebhookDeliveries(ctx context.Context, event string, payload []byte) error {
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO webhook_deliveries(webhook_id, event, payload)
+		SELECT id, $1, $2 FROM webhooks WHERE events @> ARRAY[$1::text]`,
+		event,
+		string(payload),
+	)
+	if err != nil {
+		r.log.Error("database: failed to enqueue webhook deliveries", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+// ClaimWebhookDeliveries returns the pending deliveries due for an attempt.
+// They are leased by moving the next attempt forward, so other replicas skip
+// them while the attempt is in progress.
+func (r *Storage) ClaimWebhookDeliveries(
+	ctx context.Context, limit int, lease time.Duration,
+) (*[]structures.WebhookDelivery, error) {
+	deliveries := make([]structures.WebhookDelivery, 0, limit)
+	err := r.db.Select(
+		ctx,
+		&deliveries,
+		`WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due, webhooks w
+		WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING `+prefixColumns("d", deliveryColumns)+`, w.url, w.secret`,
+		limit,
+		lease.Seconds(),
+	)
+	if err != nil {
+		r.log.Error("database: failed to claim webhook deliveries", slog.Any("error", err))
+		return nil, err
+	}
+	return &deliveries, nil
+}
+
+func (r *Storage) UpdateWebhookDelivery(ctx context.Context, delivery structures.WebhookDelivery) error {
+	_, err := r.db.Exec(
+		ctx,
+		`UPDATE webhook_deliveries SET status = $2, attempts = $3, next_attempt_at = $4, response_code = $5,
+			last_error = $6, updated_at = NOW()
+		WHERE id = $1`,
+		delivery.Id,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.ResponseCode,
+		delivery.LastError,
+	)
+	if err != nil {
+		r.log.Error("database: failed to update webhook delivery", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns the delivery log of the webhook, newest first.
+func (r *Storage) GetWebhookDeliveries(
+	ctx context.Context, webhookId, beforeId, limit int,
+) (*[]structures.WebhookDelivery, error) {
+	deliveries := make([]structures.WebhookDelivery, 0, limit)
+	err := r.db.Select(
+		ctx,
+		&deliveries,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = 0 OR id < $2)
+		ORDER BY id DESC LIMIT $3`,
+		webhookId,
+		beforeId,
+		limit,
+	)
+	if err != nil {
+		r.log.Error("database: failed to get webhook deliveries", slog.Any("error", err))
+		return nil, err
+	}
+	return &deliveries, nil
+}
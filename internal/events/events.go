@@ -0,0 +1,84 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/google/uuid"
+)
+
+type Type string
+
+const (
+	FlatCreated  Type = "flat.created"
+	FlatApproved Type = "flat.approved"
+	FlatDeclined Type = "flat.declined"
+	FlatClaimed  Type = "flat.claimed"
+	FlatReleased Type = "flat.released"
+	// FlatStatusChanged is published for every moderation status change
+	FlatStatusChanged Type = "flat.status_changed"
+	FlatPriceChanged  Type = "flat.price_changed"
+)
+
+// Event is a change of a listing other parts of the service react to.
+type Event struct {
+	Type     Type            `json:"type"`
+	Flat     structures.Flat `json:"flat"`
+	OldPrice int             `json:"old_price,omitempty"`
+	// ModeratorId is the moderator who claimed the flat, it is hidden in
+	// the flat itself and reaches the moderators only, webhooks leave it
+	// out.
+	ModeratorId *uuid.UUID `json:"moderator_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func New(eventType Type, flat structures.Flat) Event {
+	return Event{Type: eventType, Flat: flat, ModeratorId: flat.ModeratorId, CreatedAt: time.Now().UTC()}
+}
+
+// StatusEvents returns the events of a moderation status change: the
+// generic status change and the event of the new status if it has one.
+func StatusEvents(flat structures.Flat, previous string) []Event {
+	if flat.Status == previous {
+		return nil
+	}
+
+	result := []Event{New(FlatStatusChanged, flat)}
+	switch {
+	case flat.Status == "approved":
+		result = append(result, New(FlatApproved, flat))
+	case flat.Status == "declined":
+		result = append(result, New(FlatDeclined, flat))
+	case flat.Status == "on moderation":
+		result = append(result, New(FlatClaimed, flat))
+	case previous == "on moderation":
+		result = append(result, New(FlatReleased, flat))
+	}
+	return result
+}
+
+// Bus passes the events to the subscribed handlers synchronously, a handler
+// must not block the publisher.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []func(Event)
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(handler func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *Bus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,68 @@
+package events
+
+import "sync"
+
+// subscriptionBuffer is the number of events a subscriber may lag behind,
+// further events are dropped for it instead of blocking the publisher.
+const subscriptionBuffer = 16
+
+// Hub fans the events out to the subscribers of the flat's house and to the
+// subscribers of all houses.
+type Hub struct {
+	mu     sync.RWMutex
+	houses map[int]map[*Subscription]struct{}
+}
+
+// allHouses is the subscription key of all houses, house ids start at 1.
+const allHouses = 0
+
+type Subscription struct {
+	Events  <-chan Event
+	events  chan Event
+	houseId int
+	hub     *Hub
+}
+
+func NewHub() *Hub {
+	return &Hub{houses: make(map[int]map[*Subscription]struct{})}
+}
+
+func (h *Hub) Subscribe(houseId int) *Subscription {
+	events := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{Events: events, events: events, houseId: houseId, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.houses[houseId] == nil {
+		h.houses[houseId] = make(map[*Subscription]struct{})
+	}
+	h.houses[houseId][sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) SubscribeAll() *Subscription {
+	return h.Subscribe(allHouses)
+}
+
+func (h *Hub) Publish(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, houseId := range []int{event.Flat.HouseId, allHouses} {
+		for sub := range h.houses[houseId] {
+			select {
+			case sub.events <- event:
+			default:
+			}
+		}
+	}
+}
+
+// Close stops the subscription, the Events channel is not closed.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	delete(s.hub.houses[s.houseId], s)
+	if len(s.hub.houses[s.houseId]) == 0 {
+		delete(s.hub.houses, s.houseId)
+	}
+}
@@ -0,0 +1,75 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+func receive(t *testing.T, sub *Subscription) (Event, bool) {
+	t.Helper()
+	select {
+	case event := <-sub.Events:
+		return event, true
+	default:
+		return Event{}, false
+	}
+}
+
+func TestHubPublish(t *testing.T) {
+	hub := NewHub()
+	house := hub.Subscribe(1)
+	other := hub.Subscribe(2)
+	all := hub.SubscribeAll()
+
+	hub.Publish(New(FlatCreated, structures.Flat{Id: 10, HouseId: 1}))
+
+	if event, ok := receive(t, house); !ok || event.Flat.Id != 10 {
+		t.Errorf("house subscriber got %+v, %v, want flat 10", event, ok)
+	}
+	if event, ok := receive(t, all); !ok || event.Flat.Id != 10 {
+		t.Errorf("all houses subscriber got %+v, %v, want flat 10", event, ok)
+	}
+	if event, ok := receive(t, other); ok {
+		t.Errorf("other house subscriber got %+v, want nothing", event)
+	}
+}
+
+func TestHubDropsForSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(1)
+
+	for i := 0; i < subscriptionBuffer+5; i++ {
+		hub.Publish(New(FlatCreated, structures.Flat{Id: i, HouseId: 1}))
+	}
+
+	for i := 0; i < subscriptionBuffer; i++ {
+		if event, ok := receive(t, sub); !ok || event.Flat.Id != i {
+			t.Fatalf("event %d = %+v, %v, want flat %d", i, event, ok, i)
+		}
+	}
+	if event, ok := receive(t, sub); ok {
+		t.Errorf("got %+v over the buffer, want it dropped", event)
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	hub := NewHub()
+	first := hub.Subscribe(1)
+	second := hub.Subscribe(1)
+
+	first.Close()
+	hub.Publish(New(FlatCreated, structures.Flat{Id: 10, HouseId: 1}))
+
+	if event, ok := receive(t, first); ok {
+		t.Errorf("closed subscriber got %+v", event)
+	}
+	if _, ok := receive(t, second); !ok {
+		t.Error("open subscriber got nothing")
+	}
+
+	second.Close()
+	if len(hub.houses) != 0 {
+		t.Errorf("hub keeps %d houses after all subscriptions closed", len(hub.houses))
+	}
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/db"
+	"github.com/google/uuid"
+)
+
+const (
+	relayChannel = "listing_events"
+	relayRetry   = 5 * time.Second
+	// maxNotifyPayload keeps the payload under the 8000 bytes limit of
+	// pg_notify
+	maxNotifyPayload = 7900
+)
+
+type notifier interface {
+	Notify(ctx context.Context, channel, payload string) error
+	Listen(ctx context.Context, channel string, retry time.Duration, listener db.Listener) error
+}
+
+type envelope struct {
+	Origin string `json:"origin"`
+	Event  Event  `json:"event"`
+}
+
+// Relay passes the events between the replicas with Postgres LISTEN/NOTIFY.
+// Local events reach the hub directly, so the relay skips its own ones.
+type Relay struct {
+	log    *slog.Logger
+	db     notifier
+	hub    *Hub
+	origin string
+}
+
+func NewRelay(log *slog.Logger, db notifier, hub *Hub) *Relay {
+	return &Relay{log: log, db: db, hub: hub, origin: uuid.NewString()}
+}
+
+// Run passes the events of other replicas to the hub until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	_ = r.db.Listen(ctx, relayChannel, relayRetry, r)
+}
+
+func (r *Relay) Publish(ctx context.Context, event Event) {
+	payload, err := json.Marshal(envelope{Origin: r.origin, Event: event})
+	if err == nil && len(payload) > maxNotifyPayload {
+		event.Flat.Description = ""
+		event.Flat.Photos = nil
+		payload, err = json.Marshal(envelope{Origin: r.origin, Event: event})
+	}
+	if err != nil {
+		r.log.Error("failed to marshal event", slog.Any("error", err))
+		return
+	}
+	if err = r.db.Notify(ctx, relayChannel, string(payload)); err != nil {
+		r.log.Error("failed to relay event", slog.Any("error", err))
+	}
+}
+
+func (r *Relay) Connected() {
+	r.log.Info("listening for events of other replicas")
+}
+
+func (r *Relay) Disconnected(err error) {
+	r.log.Error("events listener disconnected", slog.Any("error", err))
+}
+
+func (r *Relay) Notification(payload string) {
+	var msg envelope
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		r.log.Error("failed to unmarshal relayed event", slog.Any("error", err))
+		return
+	}
+	if msg.Origin == r.origin {
+		return
+	}
+	r.hub.Publish(msg.Event)
+}
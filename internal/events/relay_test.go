@@ -0,0 +1,97 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/db"
+)
+
+var discardLog = slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+// fakeNotifier delivers the notifications to the relays listening on it as
+// Postgres does, the sender included.
+type fakeNotifier struct {
+	payloads  []string
+	listeners []db.Listener
+}
+
+func (f *fakeNotifier) Notify(_ context.Context, _, payload string) error {
+	f.payloads = append(f.payloads, payload)
+	for _, listener := range f.listeners {
+		listener.Notification(payload)
+	}
+	return nil
+}
+
+func (f *fakeNotifier) Listen(context.Context, string, time.Duration, db.Listener) error {
+	return nil
+}
+
+func TestRelayPassesEventsOfOtherReplicas(t *testing.T) {
+	notifier := &fakeNotifier{}
+	localHub, remoteHub := NewHub(), NewHub()
+	local := NewRelay(discardLog, notifier, localHub)
+	remote := NewRelay(discardLog, notifier, remoteHub)
+	notifier.listeners = []db.Listener{local, remote}
+
+	localSub, remoteSub := localHub.Subscribe(1), remoteHub.Subscribe(1)
+	local.Publish(context.Background(), New(FlatApproved, structures.Flat{Id: 10, HouseId: 1}))
+
+	if event, ok := receive(t, localSub); ok {
+		t.Errorf("own event relayed back: %+v", event)
+	}
+	event, ok := receive(t, remoteSub)
+	if !ok {
+		t.Fatal("event of other replica not relayed")
+	}
+	if event.Type != FlatApproved || event.Flat.Id != 10 {
+		t.Errorf("relayed event = %+v, want approved flat 10", event)
+	}
+}
+
+func TestRelayTrimsLargeEvents(t *testing.T) {
+	notifier := &fakeNotifier{}
+	relay := NewRelay(discardLog, notifier, NewHub())
+
+	flat := structures.Flat{
+		Id:          10,
+		HouseId:     1,
+		Description: strings.Repeat("x", maxNotifyPayload),
+		Photos:      []structures.Photo{{Id: 1}},
+	}
+	relay.Publish(context.Background(), New(FlatApproved, flat))
+
+	if len(notifier.payloads) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(notifier.payloads))
+	}
+	payload := notifier.payloads[0]
+	if len(payload) > maxNotifyPayload {
+		t.Errorf("payload of %d bytes over the limit", len(payload))
+	}
+	var msg envelope
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+	if msg.Event.Flat.Id != 10 || msg.Event.Flat.Description != "" || msg.Event.Flat.Photos != nil {
+		t.Errorf("relayed flat = %+v, want flat 10 without description and photos", msg.Event.Flat)
+	}
+}
+
+func TestRelayIgnoresMalformedPayload(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(1)
+	relay := NewRelay(discardLog, &fakeNotifier{}, hub)
+
+	relay.Notification("{")
+
+	if event, ok := receive(t, sub); ok {
+		t.Errorf("malformed payload published %+v", event)
+	}
+}
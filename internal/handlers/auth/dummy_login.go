@@ -6,6 +6,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
@@ -13,6 +14,7 @@ import (
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
 const (
@@ -23,6 +25,7 @@ const (
 type claims struct {
 	jwt.RegisteredClaims
 	TypeUser string
+	UserId   uuid.UUID
 }
 
 type dummyLoginRequest struct {
@@ -33,11 +36,12 @@ type tokenResponse struct {
 	Token string `json:"token"`
 }
 
-func BuildJWTString(typeUser string) (string, error) {
+func BuildJWTString(typeUser string, userId uuid.UUID) (string, error) {
 	token := jwt.NewWithClaims(
 		jwt.SigningMethodHS256, claims{
 			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp))},
 			TypeUser:         typeUser,
+			UserId:           userId,
 		},
 	)
 	signedString, err := token.SignedString([]byte(SecretKey))
@@ -71,6 +75,46 @@ func IsAuthorized(tokenString string) bool {
 }
 
 func GetUserType(tokenString string) string {
+	data, err := parseClaims(tokenString)
+	if err != nil {
+		return ""
+	}
+	return data.TypeUser
+}
+
+// GetUserId returns the id of the token owner, uuid.Nil for invalid tokens.
+func GetUserId(tokenString string) uuid.UUID {
+	data, err := parseClaims(tokenString)
+	if err != nil {
+		return uuid.Nil
+	}
+	return data.UserId
+}
+
+// RequireUserId returns the id of the request token owner. Tokens issued
+// before the user id claim was added have no owner, for them it responds
+// with 401 and returns false.
+func RequireUserId(w http.ResponseWriter, r *http.Request, log *slog.Logger, requestId string) (uuid.UUID, bool) {
+	userId := GetUserId(GetToken(r))
+	if userId == uuid.Nil {
+		services.MakeErrorResponse(
+			w, r, log, "token has no user id, log in again", http.StatusUnauthorized, requestId, nil,
+		)
+		return uuid.Nil, false
+	}
+	return userId, true
+}
+
+// GetToken returns the bearer token of the request.
+func GetToken(r *http.Request) string {
+	arr := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(arr) != 2 {
+		return ""
+	}
+	return arr[1]
+}
+
+func parseClaims(tokenString string) (*claims, error) {
 	data := &claims{}
 
 	_, err := jwt.ParseWithClaims(
@@ -83,9 +127,9 @@ func GetUserType(tokenString string) string {
 		},
 	)
 	if err != nil {
-		return ""
+		return nil, err
 	}
-	return data.TypeUser
+	return data, nil
 }
 
 func GetDummyLogin(log *slog.Logger) http.HandlerFunc {
@@ -129,7 +173,8 @@ func GetDummyLogin(log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
-		jwtString, err := BuildJWTString(req.UserType)
+		// dummy users are not stored, every token gets a new identity
+		jwtString, err := BuildJWTString(req.UserType, uuid.New())
 		if err != nil {
 			services.MakeErrorResponse(w, r, log, "invalid jwt parse", http.StatusInternalServerError, requestId, err)
 			return
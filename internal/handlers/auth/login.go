@@ -91,7 +91,7 @@ func Login(ctx context.Context, log *slog.Logger, data getUser) http.HandlerFunc
 			return
 		}
 
-		jwtString, err := BuildJWTString(user.Type)
+		jwtString, err := BuildJWTString(user.Type, user.Id)
 		if err != nil {
 			services.MakeErrorResponse(w, r, log, "invalid jwt parse", http.StatusBadRequest, requestId, err)
 			return
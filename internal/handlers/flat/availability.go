@@ -0,0 +1,144 @@
+package flat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/response"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const (
+	availabilityActive    = "active"
+	availabilitySold      = "sold"
+	availabilityWithdrawn = "withdrawn"
+)
+
+// availabilityTransitions lists the allowed changes, a sold flat is final.
+var availabilityTransitions = map[string][]string{
+	availabilityActive:    {availabilitySold, availabilityWithdrawn},
+	availabilityWithdrawn: {availabilityActive, availabilitySold},
+}
+
+type availabilityRequest struct {
+	Availability string `json:"availability" validate:"required,oneof=active sold withdrawn"`
+}
+
+type availabilityUpdater interface {
+	GetFlat(ctx context.Context, id int) (*structures.Flat, error)
+	UpdateAvailability(ctx context.Context, id int, from, to string) (*structures.Flat, error)
+}
+
+// SetAvailability lets the author mark the flat sold or withdraw it, which
+// hides it from clients regardless of the moderation status.
+func SetAvailability(ctx context.Context, log *slog.Logger, updater availabilityUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req availabilityRequest
+		var err error
+		const op = "handlers.flat.setAvailability"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get id from url param", http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		// decode
+		err = render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			services.MakeErrorResponse(w, r, log, "request body is empty", http.StatusBadRequest, requestId, err)
+			return
+		}
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to decode request body",
+				http.StatusBadRequest,
+				requestId,
+				err,
+			)
+			return
+		}
+		log.Info("request body decoded")
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("Invalid request")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(validateErr, requestId))
+			return
+		}
+
+		flat, err := updater.GetFlat(ctx, id)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to find flat", http.StatusBadRequest, requestId, err)
+			return
+		}
+		if !isAuthor(auth.GetToken(r), flat) {
+			services.MakeErrorResponse(w, r, log, "user is not the author of the flat", http.StatusForbidden, requestId, nil)
+			return
+		}
+
+		if flat.Availability != req.Availability {
+			if !canChangeAvailability(flat.Availability, req.Availability) {
+				err = fmt.Errorf("flat can not change from %s to %s", flat.Availability, req.Availability)
+				services.MakeErrorResponse(w, r, log, err.Error(), http.StatusConflict, requestId, err)
+				return
+			}
+			flat, err = updater.UpdateAvailability(ctx, id, flat.Availability, req.Availability)
+			if errors.Is(err, structures.ErrAvailabilityChanged) {
+				services.MakeErrorResponse(w, r, log, err.Error(), http.StatusConflict, requestId, err)
+				return
+			}
+			if err != nil {
+				services.MakeErrorResponse(
+					w,
+					r,
+					log,
+					"failed to update availability",
+					http.StatusInternalServerError,
+					requestId,
+					err,
+				)
+				return
+			}
+		}
+
+		render.JSON(w, r, &flat)
+	}
+}
+
+func canChangeAvailability(from, to string) bool {
+	for _, allowed := range availabilityTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func isAuthor(token string, flat *structures.Flat) bool {
+	userId := auth.GetUserId(token)
+	return flat.AuthorId != nil && userId != uuid.Nil && *flat.AuthorId == userId
+}
@@ -0,0 +1,88 @@
+package flat
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// fakeAvailability serves the flat as it was read and changes the stored
+// one only from the expected availability, the way the storage does.
+type fakeAvailability struct {
+	read   structures.Flat
+	stored string
+}
+
+func (f *fakeAvailability) GetFlat(context.Context, int) (*structures.Flat, error) {
+	flat := f.read
+	return &flat, nil
+}
+
+func (f *fakeAvailability) UpdateAvailability(_ context.Context, _ int, from, to string) (*structures.Flat, error) {
+	if f.stored != from {
+		return nil, structures.ErrAvailabilityChanged
+	}
+	f.stored = to
+	flat := f.read
+	flat.Availability = to
+	return &flat, nil
+}
+
+func setAvailability(t *testing.T, updater availabilityUpdater, authorId uuid.UUID, availability string) int {
+	t.Helper()
+	token, err := auth.BuildJWTString("client", authorId)
+	if err != nil {
+		t.Fatalf("failed to build token: %v", err)
+	}
+	router := chi.NewRouter()
+	router.Post(
+		"/flat/{id}/availability",
+		SetAvailability(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), updater),
+	)
+	req := httptest.NewRequest(
+		http.MethodPost, "/flat/1/availability", strings.NewReader(`{"availability":"`+availability+`"}`),
+	)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestSetAvailability(t *testing.T) {
+	authorId := uuid.New()
+	tests := []struct {
+		name   string
+		read   string
+		stored string
+		to     string
+		want   int
+	}{
+		{"sell", availabilityActive, availabilityActive, availabilitySold, http.StatusOK},
+		{"sold is final", availabilitySold, availabilitySold, availabilityActive, http.StatusConflict},
+		// another request sold the withdrawn flat after it was read
+		{"changed meanwhile", availabilityWithdrawn, availabilitySold, availabilityActive, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updater := &fakeAvailability{
+				read:   structures.Flat{Id: 1, AuthorId: &authorId, Availability: tt.read},
+				stored: tt.stored,
+			}
+			if code := setAvailability(t, updater, authorId, tt.to); code != tt.want {
+				t.Errorf("status = %d, want %d", code, tt.want)
+			}
+			if tt.want != http.StatusOK && updater.stored != tt.stored {
+				t.Errorf("availability = %s, want %s kept", updater.stored, tt.stored)
+			}
+		})
+	}
+}
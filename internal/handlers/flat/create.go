@@ -9,25 +9,65 @@ import (
 	"time"
 
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/moderation"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/response"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
 type flatRequest struct {
-	HouseId int `json:"house_id" validate:"required"`
-	Price   int `json:"price" validate:"required,min=0"`
-	Rooms   int `json:"rooms" validate:"required,min=1"`
+	HouseId     int      `json:"house_id" validate:"required"`
+	Price       int      `json:"price" validate:"required,min=0"`
+	Rooms       int      `json:"rooms" validate:"required,min=1"`
+	Number      *int     `json:"number" validate:"omitempty,min=1"`
+	Area        *float64 `json:"area" validate:"omitempty,gt=0,lt=100000"`
+	Floor       *int     `json:"floor" validate:"omitempty,min=-10,max=500"`
+	Description string   `json:"description" validate:"max=5000"`
 }
 
 type houseSaver interface {
-	SaveFlat(ctx context.Context, houseId, price, rooms int) (*structures.Flat, error)
+	SaveFlat(ctx context.Context, flat structures.Flat) (*structures.Flat, error)
 	UpdateDate(ctx context.Context, time time.Time, id int) error
 }
 
-func Create(ctx context.Context, log *slog.Logger, saver houseSaver) http.HandlerFunc {
+type preModeration interface {
+	Apply(ctx context.Context, flat *structures.Flat) moderation.Decision
+}
+
+type publisher interface {
+	Publish(event events.Event)
+}
+
+// publishStatus publishes the moderation status change of the flat.
+func publishStatus(bus publisher, flat structures.Flat, previous string) {
+	for _, event := range events.StatusEvents(flat, previous) {
+		bus.Publish(event)
+	}
+}
+
+// publishApprovedPrice publishes the price change of an edited flat once it
+// is approved with the new price, the old price is the one the clients saw.
+func publishApprovedPrice(bus publisher, flat structures.Flat, previous structures.Flat) {
+	if flat.Status != "approved" || previous.ApprovedPrice == nil || *previous.ApprovedPrice == flat.Price {
+		return
+	}
+	event := events.New(events.FlatPriceChanged, flat)
+	event.OldPrice = *previous.ApprovedPrice
+	bus.Publish(event)
+}
+
+func Create(
+	ctx context.Context,
+	log *slog.Logger,
+	saver houseSaver,
+	rules preModeration,
+	bus publisher,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req flatRequest
 		var err error
@@ -69,7 +109,40 @@ func Create(ctx context.Context, log *slog.Logger, saver houseSaver) http.Handle
 			return
 		}
 
-		flat, err := saver.SaveFlat(ctx, req.HouseId, req.Price, req.Rooms)
+		var authorId *uuid.UUID
+		if userId := auth.GetUserId(auth.GetToken(r)); userId != uuid.Nil {
+			authorId = &userId
+		}
+
+		newFlat := structures.Flat{
+			HouseId:     req.HouseId,
+			Price:       req.Price,
+			Rooms:       req.Rooms,
+			Number:      req.Number,
+			Area:        req.Area,
+			Floor:       req.Floor,
+			Description: req.Description,
+			AuthorId:    authorId,
+			Status:      "created",
+		}
+		// the flat is saved with the outcome of the rules, so it is never
+		// visible to moderators before the rules are applied
+		decision := rules.Apply(ctx, &newFlat)
+		log.Info("pre-moderation finished", slog.String("action", string(decision.Action)))
+
+		flat, err := saver.SaveFlat(ctx, newFlat)
+		if errors.Is(err, structures.ErrAlreadyExists) {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"flat with this number already exists in the house",
+				http.StatusConflict,
+				requestId,
+				err,
+			)
+			return
+		}
 		if err != nil {
 			services.MakeErrorResponse(w, r, log, "failed to save flat to db", http.StatusBadRequest, requestId, err)
 			return
@@ -88,6 +161,10 @@ func Create(ctx context.Context, log *slog.Logger, saver houseSaver) http.Handle
 			)
 			return
 		}
+
+		bus.Publish(events.New(events.FlatCreated, *flat))
+		publishStatus(bus, *flat, "created")
+
 		render.JSON(w, r, &flat)
 	}
 }
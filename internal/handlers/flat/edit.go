@@ -0,0 +1,134 @@
+package flat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/response"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type editRequest struct {
+	Id          int      `json:"id" validate:"required,min=1"`
+	Price       int      `json:"price" validate:"required,min=0"`
+	Rooms       int      `json:"rooms" validate:"required,min=1"`
+	Number      *int     `json:"number" validate:"omitempty,min=1"`
+	Area        *float64 `json:"area" validate:"omitempty,gt=0,lt=100000"`
+	Floor       *int     `json:"floor" validate:"omitempty,min=-10,max=500"`
+	Description string   `json:"description" validate:"max=5000"`
+}
+
+type flatEditor interface {
+	GetFlat(ctx context.Context, id int) (*structures.Flat, error)
+	UpdateFlat(ctx context.Context, flat structures.Flat) (*structures.Flat, error)
+}
+
+// Edit replaces the details of the flat, only its author or a moderator may
+// edit it. The edited flat goes through the moderation again.
+func Edit(
+	ctx context.Context, log *slog.Logger, editor flatEditor, rules preModeration, bus publisher,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req editRequest
+		var err error
+		const op = "handlers.flat.edit"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		// decode
+		err = render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			services.MakeErrorResponse(w, r, log, "request body is empty", http.StatusBadRequest, requestId, err)
+			return
+		}
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to decode request body",
+				http.StatusBadRequest,
+				requestId,
+				err,
+			)
+			return
+		}
+		log.Info("request body decoded")
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("Invalid request")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(validateErr, requestId))
+			return
+		}
+
+		current, err := editor.GetFlat(ctx, req.Id)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to find flat", http.StatusBadRequest, requestId, err)
+			return
+		}
+		if !canEdit(auth.GetToken(r), current) {
+			services.MakeErrorResponse(w, r, log, "user is not the author of the flat", http.StatusForbidden, requestId, nil)
+			return
+		}
+
+		edited := structures.Flat{
+			Id:          req.Id,
+			HouseId:     current.HouseId,
+			Price:       req.Price,
+			Rooms:       req.Rooms,
+			Number:      req.Number,
+			Area:        req.Area,
+			Floor:       req.Floor,
+			Description: req.Description,
+			AuthorId:    current.AuthorId,
+			Status:      "created",
+		}
+		// the edited flat is saved with the decision, so it never waits in
+		// the queue unchecked
+		decision := rules.Apply(ctx, &edited)
+		log.Info("pre-moderation finished", slog.String("action", string(decision.Action)))
+
+		flat, err := editor.UpdateFlat(ctx, edited)
+		if errors.Is(err, structures.ErrAlreadyExists) {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"flat with this number already exists in the house",
+				http.StatusConflict,
+				requestId,
+				err,
+			)
+			return
+		}
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to update flat", http.StatusInternalServerError, requestId, err)
+			return
+		}
+
+		if flat.Price != current.Price {
+			event := events.New(events.FlatPriceChanged, *flat)
+			event.OldPrice = current.Price
+			bus.Publish(event)
+		}
+		publishStatus(bus, *flat, current.Status)
+
+		render.JSON(w, r, &flat)
+	}
+}
@@ -0,0 +1,126 @@
+package flat
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type favorites interface {
+	GetFlat(ctx context.Context, id int) (*structures.Flat, error)
+	AddFavorite(ctx context.Context, userId uuid.UUID, flatId int) error
+	RemoveFavorite(ctx context.Context, userId uuid.UUID, flatId int) error
+	GetFavorites(ctx context.Context, userId uuid.UUID) (*[]structures.Flat, error)
+}
+
+type FavoritesResponse struct {
+	Flats *[]structures.Flat `json:"flats"`
+}
+
+// AddFavorite saves an approved flat to the favorites of the user.
+func AddFavorite(ctx context.Context, log *slog.Logger, source favorites) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.flat.addFavorite"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get id from url param", http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		flat, err := source.GetFlat(ctx, id)
+		if err != nil || flat.Status != "approved" {
+			services.MakeErrorResponse(w, r, log, "failed to find flat", http.StatusNotFound, requestId, err)
+			return
+		}
+
+		if err = source.AddFavorite(ctx, userId, id); err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to add favorite", http.StatusInternalServerError, requestId, err)
+			return
+		}
+
+		render.JSON(w, r, &flat)
+	}
+}
+
+// RemoveFavorite deletes the flat from the favorites of the user.
+func RemoveFavorite(ctx context.Context, log *slog.Logger, source favorites) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.flat.removeFavorite"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get id from url param", http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		if err = source.RemoveFavorite(ctx, userId, id); err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to remove favorite",
+				http.StatusInternalServerError,
+				requestId,
+				err,
+			)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Favorites returns the approved flats saved by the user.
+func Favorites(ctx context.Context, log *slog.Logger, source favorites) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.flat.favorites"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		flats, err := source.GetFavorites(ctx, userId)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get favorites", http.StatusInternalServerError, requestId, err)
+			return
+		}
+
+		render.JSON(w, r, &FavoritesResponse{Flats: flats})
+	}
+}
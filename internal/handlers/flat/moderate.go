@@ -8,26 +8,26 @@ import (
 	"net/http"
 
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/response"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
-const on_moderate = "on moderate"
-
 type moderationRequest struct {
 	Id     int    `json:"id" validate:"required,min=1"`
 	Status string `json:"status" validate:"required,oneof='created' 'approved' 'declined' 'on moderation'"`
 }
 
 type updateModeration interface {
-	UpdateStatus(ctx context.Context, id int, status string) error
+	UpdateStatus(ctx context.Context, id int, status string, moderatorId uuid.UUID) (*structures.Flat, error)
 	GetFlat(ctx context.Context, id int) (*structures.Flat, error)
 }
 
-func Moderate(ctx context.Context, log *slog.Logger, moderation updateModeration) http.HandlerFunc {
+func Moderate(ctx context.Context, log *slog.Logger, moderation updateModeration, bus publisher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req moderationRequest
 		var err error
@@ -38,6 +38,11 @@ func Moderate(ctx context.Context, log *slog.Logger, moderation updateModeration
 			slog.String("request_id", requestId),
 		)
 
+		moderatorId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
 		// decode
 		err = render.DecodeJSON(r.Body, &req)
 		if errors.Is(err, io.EOF) {
@@ -74,7 +79,9 @@ func Moderate(ctx context.Context, log *slog.Logger, moderation updateModeration
 			return
 		}
 
-		if notChangedFlat.Status == on_moderate {
+		// the status is not changed if another moderator claimed the flat
+		flat, err := moderation.UpdateStatus(ctx, req.Id, req.Status, moderatorId)
+		if errors.Is(err, structures.ErrNotClaimable) {
 			services.MakeErrorResponse(
 				w,
 				r,
@@ -86,18 +93,12 @@ func Moderate(ctx context.Context, log *slog.Logger, moderation updateModeration
 			)
 			return
 		}
-
-		err = moderation.UpdateStatus(ctx, req.Id, req.Status)
 		if err != nil {
 			services.MakeErrorResponse(w, r, log, "failed to update status", http.StatusBadRequest, requestId, err)
 			return
 		}
-
-		flat, err := moderation.GetFlat(ctx, req.Id)
-		if err != nil {
-			services.MakeErrorResponse(w, r, log, "failed to find flat", http.StatusBadRequest, requestId, err)
-			return
-		}
+		publishStatus(bus, *flat, notChangedFlat.Status)
+		publishApprovedPrice(bus, *flat, *notChangedFlat)
 
 		render.JSON(w, r, &flat)
 	}
@@ -0,0 +1,124 @@
+package flat
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/moderation"
+	"github.com/google/uuid"
+)
+
+// fakeFlats keeps one flat and remembers the approved price the way the
+// storage does.
+type fakeFlats struct {
+	flat structures.Flat
+}
+
+func (f *fakeFlats) GetFlat(context.Context, int) (*structures.Flat, error) {
+	flat := f.flat
+	return &flat, nil
+}
+
+func (f *fakeFlats) UpdateFlat(_ context.Context, flat structures.Flat) (*structures.Flat, error) {
+	flat.ApprovedPrice = f.flat.ApprovedPrice
+	f.flat = flat
+	return f.GetFlat(context.Background(), flat.Id)
+}
+
+func (f *fakeFlats) UpdateStatus(_ context.Context, _ int, status string, _ uuid.UUID) (*structures.Flat, error) {
+	f.flat.Status = status
+	if status == "approved" {
+		price := f.flat.Price
+		f.flat.ApprovedPrice = &price
+	}
+	return f.GetFlat(context.Background(), f.flat.Id)
+}
+
+type passRules struct{}
+
+func (passRules) Apply(context.Context, *structures.Flat) moderation.Decision {
+	return moderation.Decision{Action: moderation.ActionPass}
+}
+
+type recordingBus struct {
+	events []events.Event
+}
+
+func (b *recordingBus) Publish(event events.Event) {
+	b.events = append(b.events, event)
+}
+
+func (b *recordingBus) find(eventType events.Type) []events.Event {
+	found := make([]events.Event, 0)
+	for _, event := range b.events {
+		if event.Type == eventType {
+			found = append(found, event)
+		}
+	}
+	return found
+}
+
+func moderatorRequest(t *testing.T, handler http.HandlerFunc, body string) int {
+	t.Helper()
+	token, err := auth.BuildJWTString(moderatorType, uuid.New())
+	if err != nil {
+		t.Fatalf("failed to build token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec.Code
+}
+
+func TestModerateAnnouncesPriceOfEditedFlat(t *testing.T) {
+	approvedPrice := 1000
+	flats := &fakeFlats{
+		flat: structures.Flat{Id: 1, HouseId: 2, Price: 1000, Rooms: 1, Status: "approved", ApprovedPrice: &approvedPrice},
+	}
+	bus := &recordingBus{}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	edit := Edit(ctx, log, flats, passRules{}, bus)
+	if code := moderatorRequest(t, edit, `{"id":1,"price":900,"rooms":1}`); code != http.StatusOK {
+		t.Fatalf("edit status = %d, want %d", code, http.StatusOK)
+	}
+	// the edited flat waits for the moderation, the clients do not see it
+	for _, event := range bus.find(events.FlatPriceChanged) {
+		if event.Flat.Status == "approved" {
+			t.Fatalf("price change of the edited flat published as approved: %+v", event)
+		}
+	}
+	bus.events = nil
+
+	moderate := Moderate(ctx, log, flats, bus)
+	if code := moderatorRequest(t, moderate, `{"id":1,"status":"approved"}`); code != http.StatusOK {
+		t.Fatalf("moderate status = %d, want %d", code, http.StatusOK)
+	}
+	changed := bus.find(events.FlatPriceChanged)
+	if len(changed) != 1 {
+		t.Fatalf("price changes = %+v, want one on the approval", bus.events)
+	}
+	if changed[0].OldPrice != 1000 || changed[0].Flat.Price != 900 || changed[0].Flat.Status != "approved" {
+		t.Errorf("price change = %d -> %d (%s), want 1000 -> 900 approved",
+			changed[0].OldPrice, changed[0].Flat.Price, changed[0].Flat.Status)
+	}
+
+	// approving the same price again is not a change
+	bus.events = nil
+	if code := moderatorRequest(t, moderate, `{"id":1,"status":"approved"}`); code != http.StatusOK {
+		t.Fatalf("moderate status = %d, want %d", code, http.StatusOK)
+	}
+	if changed = bus.find(events.FlatPriceChanged); len(changed) != 0 {
+		t.Errorf("price changes = %+v, want none", changed)
+	}
+}
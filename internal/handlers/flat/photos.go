@@ -0,0 +1,280 @@
+package flat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/blob"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/imaging"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+const (
+	maxPhotoSize = 10 << 20
+	// maxPhotoPixels bounds the decoded image, a small file may declare
+	// dimensions needing gigabytes of memory
+	maxPhotoPixels   = 40_000_000
+	maxPhotosPerCall = 10
+	thumbnailSize    = 320
+	photoField       = "photo"
+	uploadTimeout    = 2 * time.Minute
+	moderatorType    = "moderator"
+)
+
+var photoExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+type photoSaver interface {
+	GetFlat(ctx context.Context, id int) (*structures.Flat, error)
+	SaveFlatPhoto(ctx context.Context, photo structures.Photo) (*structures.Photo, error)
+	UpdateStatus(ctx context.Context, id int, status string, moderatorId uuid.UUID) (*structures.Flat, error)
+}
+
+type PhotosResponse struct {
+	Photos []structures.Photo `json:"photos"`
+}
+
+type upload struct {
+	data        []byte
+	contentType string
+	thumbnail   []byte
+}
+
+func UploadPhotos(
+	ctx context.Context, log *slog.Logger, saver photoSaver, store blob.Store, bus publisher,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.flat.uploadPhotos"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get id from url param", http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		flat, err := saver.GetFlat(ctx, id)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to find flat", http.StatusBadRequest, requestId, err)
+			return
+		}
+		if !canEdit(auth.GetToken(r), flat) {
+			services.MakeErrorResponse(w, r, log, "user is not the author of the flat", http.StatusForbidden, requestId, nil)
+			return
+		}
+
+		// large uploads need more time than the server-wide timeouts allow
+		rc := http.NewResponseController(w)
+		if err = rc.SetReadDeadline(time.Now().Add(uploadTimeout)); err != nil {
+			log.Error("failed to extend read deadline", slog.Any("error", err))
+		}
+		if err = rc.SetWriteDeadline(time.Now().Add(uploadTimeout)); err != nil {
+			log.Error("failed to extend write deadline", slog.Any("error", err))
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxPhotosPerCall*maxPhotoSize+1<<20)
+		if err = r.ParseMultipartForm(maxPhotoSize); err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to parse multipart form", http.StatusBadRequest, requestId, err)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		files := r.MultipartForm.File[photoField]
+		if len(files) == 0 || len(files) > maxPhotosPerCall {
+			err = fmt.Errorf("expected 1 to %d files in the %s field", maxPhotosPerCall, photoField)
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		// every file is checked before anything is stored
+		uploads := make([]upload, 0, len(files))
+		for _, file := range files {
+			u, err := readPhoto(file)
+			if err != nil {
+				services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+				return
+			}
+			uploads = append(uploads, u)
+		}
+
+		photos := make([]structures.Photo, 0, len(uploads))
+		for _, u := range uploads {
+			photo, err := storePhoto(ctx, saver, store, flat.Id, u)
+			if err != nil {
+				services.MakeErrorResponse(w, r, log, "failed to save photo", http.StatusInternalServerError, requestId, err)
+				return
+			}
+			photos = append(photos, *photo)
+		}
+
+		// photos are moderated together with the flat, a flat on moderation
+		// stays with its moderator who sees the new photos
+		if flat.Status == "approved" || flat.Status == "declined" {
+			_, err = saver.UpdateStatus(ctx, flat.Id, "created", uuid.Nil)
+			if err != nil && !errors.Is(err, structures.ErrNotClaimable) {
+				services.MakeErrorResponse(
+					w,
+					r,
+					log,
+					"failed to send flat to moderation",
+					http.StatusInternalServerError,
+					requestId,
+					err,
+				)
+				return
+			}
+			// a flat claimed by a moderator meanwhile stays on moderation
+			if err == nil {
+				previous := flat.Status
+				flat.Status = "created"
+				publishStatus(bus, *flat, previous)
+			}
+		}
+
+		render.JSON(w, r, &PhotosResponse{Photos: photos})
+	}
+}
+
+func GetPhoto(log *slog.Logger, store blob.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestId := middleware.GetReqID(r.Context())
+		key := chi.URLParam(r, "*")
+
+		body, err := store.Get(r.Context(), key)
+		if errors.Is(err, blob.ErrNotFound) {
+			services.MakeErrorResponse(w, r, log, "photo not found", http.StatusNotFound, requestId, err)
+			return
+		}
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get photo", http.StatusInternalServerError, requestId, err)
+			return
+		}
+		defer body.Close()
+
+		w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+		// keys are never reused, so the content never changes
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		if _, err = io.Copy(w, body); err != nil {
+			log.Error("failed to write photo", slog.Any("error", err))
+		}
+	}
+}
+
+// canEdit reports whether the token owner may change the flat.
+func canEdit(token string, flat *structures.Flat) bool {
+	return auth.GetUserType(token) == moderatorType || isAuthor(token, flat)
+}
+
+func readPhoto(file *multipart.FileHeader) (upload, error) {
+	if file.Size > maxPhotoSize {
+		return upload{}, fmt.Errorf("file %s is larger than %d bytes", file.Filename, maxPhotoSize)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return upload{}, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxPhotoSize+1))
+	if err != nil {
+		return upload{}, err
+	}
+	if len(data) > maxPhotoSize {
+		return upload{}, fmt.Errorf("file %s is larger than %d bytes", file.Filename, maxPhotoSize)
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := photoExtensions[contentType]; !ok {
+		return upload{}, fmt.Errorf("file %s has unsupported type %s", file.Filename, contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return upload{}, fmt.Errorf("file %s is not a valid image", file.Filename)
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > maxPhotoPixels {
+		return upload{}, fmt.Errorf("file %s is larger than %d pixels", file.Filename, maxPhotoPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return upload{}, fmt.Errorf("file %s is not a valid image", file.Filename)
+	}
+
+	var thumbnail bytes.Buffer
+	if err = jpeg.Encode(&thumbnail, imaging.Thumbnail(img, thumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return upload{}, err
+	}
+	return upload{data: data, contentType: contentType, thumbnail: thumbnail.Bytes()}, nil
+}
+
+func storePhoto(
+	ctx context.Context, saver photoSaver, store blob.Store, flatId int, u upload,
+) (*structures.Photo, error) {
+	name := fmt.Sprintf("flats/%d/%s", flatId, uuid.NewString())
+	key := name + photoExtensions[u.contentType]
+	thumbnailKey := name + "_thumb.jpg"
+
+	if err := store.Put(ctx, key, bytes.NewReader(u.data), int64(len(u.data)), u.contentType); err != nil {
+		return nil, err
+	}
+	err := store.Put(ctx, thumbnailKey, bytes.NewReader(u.thumbnail), int64(len(u.thumbnail)), "image/jpeg")
+	if err != nil {
+		deleteBlobs(ctx, store, key)
+		return nil, err
+	}
+
+	photo, err := saver.SaveFlatPhoto(
+		ctx, structures.Photo{
+			FlatId:       flatId,
+			Key:          key,
+			ThumbnailKey: thumbnailKey,
+			URL:          store.URL(key),
+			ThumbnailURL: store.URL(thumbnailKey),
+			ContentType:  u.contentType,
+			Size:         int64(len(u.data)),
+		},
+	)
+	if err != nil {
+		deleteBlobs(ctx, store, key, thumbnailKey)
+		return nil, err
+	}
+	return photo, nil
+}
+
+// deleteBlobs removes the objects of a photo which was not saved, the
+// request context may be already done.
+func deleteBlobs(ctx context.Context, store blob.Store, keys ...string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		_ = store.Delete(ctx, key)
+	}
+}
@@ -0,0 +1,68 @@
+package flat
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type priceHistory interface {
+	GetFlat(ctx context.Context, id int) (*structures.Flat, error)
+	GetPriceHistory(ctx context.Context, flatId int) (*[]structures.PriceChange, error)
+}
+
+type PriceHistoryResponse struct {
+	History *[]structures.PriceChange `json:"history"`
+}
+
+// PriceHistory returns the price changes of the flat, clients see only the
+// history of published flats.
+func PriceHistory(ctx context.Context, log *slog.Logger, source priceHistory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.flat.priceHistory"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get id from url param", http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		flat, err := source.GetFlat(ctx, id)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to find flat", http.StatusBadRequest, requestId, err)
+			return
+		}
+		if flat.Status != "approved" && !canEdit(auth.GetToken(r), flat) {
+			services.MakeErrorResponse(w, r, log, "failed to find flat", http.StatusNotFound, requestId, nil)
+			return
+		}
+
+		history, err := source.GetPriceHistory(ctx, id)
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to get price history",
+				http.StatusInternalServerError,
+				requestId,
+				err,
+			)
+			return
+		}
+		render.JSON(w, r, &PriceHistoryResponse{History: history})
+	}
+}
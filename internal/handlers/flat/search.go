@@ -0,0 +1,82 @@
+package flat
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type flatSearcher interface {
+	SearchFlats(ctx context.Context, filter structures.FlatSearchFilter) (*[]structures.FlatSearchResult, error)
+	EstimateSearchFlats(ctx context.Context, filter structures.FlatSearchFilter) (int64, error)
+}
+
+type SearchResponse struct {
+	Flats         *[]structures.FlatSearchResult `json:"flats"`
+	NextCursor    string                         `json:"next_cursor,omitempty"`
+	TotalEstimate int64                          `json:"total_estimate,omitempty"`
+}
+
+func Search(ctx context.Context, log *slog.Logger, searcher flatSearcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.flat.search"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		filter, err := parseSearchFilter(r.URL.Query())
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+		limit := filter.Limit
+		// one extra row tells whether there is a next page
+		filter.Limit++
+
+		flats, err := searcher.SearchFlats(ctx, filter)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to search flats", http.StatusInternalServerError, requestId, err)
+			return
+		}
+
+		resp := SearchResponse{Flats: flats}
+		if len(*flats) > limit {
+			*flats = (*flats)[:limit]
+			resp.NextCursor = services.EncodeCursor(services.FlatCursor((*flats)[limit-1].Flat, filter.SortBy))
+		}
+
+		// the estimate is only needed to render the first page
+		if filter.After == nil {
+			if resp.TotalEstimate, err = searcher.EstimateSearchFlats(ctx, filter); err != nil {
+				log.Error("failed to estimate flats", slog.Any("error", err))
+			}
+		}
+		render.JSON(w, r, &resp)
+	}
+}
+
+func parseSearchFilter(values url.Values) (structures.FlatSearchFilter, error) {
+	var filter structures.FlatSearchFilter
+	var err error
+
+	if filter.FlatFilter, err = services.ParseFlatFilter(values, false); err != nil {
+		return filter, err
+	}
+	if filter.YearFrom, err = services.QueryInt(values, "year_from"); err != nil {
+		return filter, err
+	}
+	if filter.YearTo, err = services.QueryInt(values, "year_to"); err != nil {
+		return filter, err
+	}
+	filter.Developer = values.Get("developer")
+	filter.Address = values.Get("address")
+	return filter, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/geohash"
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/response"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
@@ -16,13 +17,15 @@ import (
 )
 
 type houseRequest struct {
-	Address   string `json:"address" validate:"required"`
-	Year      int    `json:"year" validate:"required,min=0"`
-	Developer string `json:"developer" validate:"required"`
+	Address   string   `json:"address" validate:"required"`
+	Year      int      `json:"year" validate:"required,min=0"`
+	Developer string   `json:"developer" validate:"required"`
+	Latitude  *float64 `json:"latitude" validate:"required_with=Longitude,omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" validate:"required_with=Latitude,omitempty,min=-180,max=180"`
 }
 
 type houseSaver interface {
-	SaveHouse(ctx context.Context, address, developer string, year int) (*structures.House, error)
+	SaveHouse(ctx context.Context, house structures.House) (*structures.House, error)
 	GetHouse(ctx context.Context, id int) (*structures.House, error)
 }
 
@@ -66,7 +69,38 @@ func Create(ctx context.Context, log *slog.Logger, saver houseSaver) http.Handle
 			return
 		}
 
-		house, err := saver.SaveHouse(ctx, req.Address, req.Developer, req.Year)
+		address := services.ParseAddress(req.Address)
+		if address.Street == "" {
+			services.MakeErrorResponse(w, r, log, "failed to parse address", http.StatusBadRequest, requestId, nil)
+			return
+		}
+		// without the building number all the houses of the street are one
+		if address.Building == "" {
+			services.MakeErrorResponse(w, r, log, "address has no building number", http.StatusBadRequest, requestId, nil)
+			return
+		}
+
+		newHouse := structures.House{
+			Address:           req.Address,
+			Year:              req.Year,
+			Developer:         req.Developer,
+			City:              address.City,
+			Street:            address.Street,
+			Building:          address.Building,
+			Block:             address.Block,
+			AddressNormalized: address.Normalized,
+			Latitude:          req.Latitude,
+			Longitude:         req.Longitude,
+		}
+		if req.Latitude != nil && req.Longitude != nil {
+			newHouse.Geohash = geohash.Encode(*req.Latitude, *req.Longitude, geohash.MaxPrecision)
+		}
+
+		house, err := saver.SaveHouse(ctx, newHouse)
+		if errors.Is(err, structures.ErrAlreadyExists) {
+			services.MakeErrorResponse(w, r, log, "house already exists", http.StatusConflict, requestId, err)
+			return
+		}
 		if err != nil {
 			services.MakeErrorResponse(w, r, log, "failed to save house to db", http.StatusBadRequest, requestId, err)
 			return
@@ -0,0 +1,112 @@
+package house
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// heartbeatInterval keeps idle streams open behind proxies.
+const heartbeatInterval = 15 * time.Second
+
+// Events streams the changes of the house flats as Server-Sent Events.
+// Clients get approved flats and price changes, moderators get every event.
+func Events(log *slog.Logger, hub *events.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.house.events"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err == nil && id < 1 {
+			err = fmt.Errorf("invalid house id %d", id)
+		}
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get id from url param", http.StatusBadRequest, requestId, err)
+			return
+		}
+		moderator := auth.GetUserType(auth.GetToken(r)) == moderatorType
+
+		// the stream outlives the server write timeout
+		rc := http.NewResponseController(w)
+		if err = rc.SetWriteDeadline(time.Time{}); err != nil {
+			services.MakeErrorResponse(w, r, log, "streaming is not supported", http.StatusInternalServerError, requestId, err)
+			return
+		}
+
+		sub := hub.Subscribe(id)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if err = rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				_, err = fmt.Fprint(w, ": ping\n\n")
+			case event := <-sub.Events:
+				if !moderator {
+					var ok bool
+					if event, ok = clientEvent(event); !ok {
+						continue
+					}
+				}
+				err = writeEvent(w, event)
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				log.Info("event stream closed", slog.Any("error", err))
+				return
+			}
+		}
+	}
+}
+
+// clientEvent returns the event if a client may see it: the approved flats
+// and the price changes of them. An edited flat goes back to moderation, its
+// new price reaches the clients once it is approved again. The moderator of
+// the flat is not shown to clients.
+func clientEvent(event events.Event) (events.Event, bool) {
+	event.ModeratorId = nil
+	switch event.Type {
+	case events.FlatApproved:
+		return event, true
+	case events.FlatPriceChanged:
+		return event, event.Flat.Status == "approved"
+	}
+	return events.Event{}, false
+}
+
+func writeEvent(w http.ResponseWriter, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
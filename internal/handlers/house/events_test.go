@@ -0,0 +1,39 @@
+package house
+
+import (
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+)
+
+func TestClientEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType events.Type
+		status    string
+		want      bool
+	}{
+		{"approved", events.FlatApproved, "approved", true},
+		{"price of approved flat", events.FlatPriceChanged, "approved", true},
+		{"price of flat on moderation", events.FlatPriceChanged, "on moderation", false},
+		{"price of created flat", events.FlatPriceChanged, "created", false},
+		{"price of declined flat", events.FlatPriceChanged, "declined", false},
+		{"created", events.FlatCreated, "created", false},
+		{"declined", events.FlatDeclined, "declined", false},
+		{"claimed", events.FlatClaimed, "on moderation", false},
+		{"status changed", events.FlatStatusChanged, "approved", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flat := structures.Flat{Id: 1, HouseId: 2, Price: 100, Status: tt.status, Description: "flat"}
+			got, ok := clientEvent(events.New(tt.eventType, flat))
+			if ok != tt.want {
+				t.Fatalf("clientEvent() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && (got.Flat.Id != flat.Id || got.Flat.Description != flat.Description) {
+				t.Errorf("clientEvent() flat = %+v, want %+v", got.Flat, flat)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package house
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/geohash"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultRadius = 1000
+	maxRadius     = 50000
+	// maxCells bounds the number of geohash prefixes in one query
+	maxCells = 32
+)
+
+type geoSearcher interface {
+	GetHousesNearby(
+		ctx context.Context, lat, lon, radius float64, cells []string, limit int,
+	) (*[]structures.HouseDistance, error)
+	GetHousesInBox(ctx context.Context, box geohash.Box, cells []string, limit int) (*[]structures.House, error)
+}
+
+type NearbyResponse struct {
+	Houses *[]structures.HouseDistance `json:"houses"`
+}
+
+func Nearby(ctx context.Context, log *slog.Logger, searcher geoSearcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.house.nearby"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		values := r.URL.Query()
+		lat, lon, err := parsePoint(values)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+		radius, ok, err := services.QueryFloat(values, "radius")
+		if err != nil || ok && (radius <= 0 || radius > maxRadius) {
+			services.MakeErrorResponse(w, r, log, "invalid radius parameter", http.StatusBadRequest, requestId, err)
+			return
+		}
+		if !ok {
+			radius = defaultRadius
+		}
+		limit, err := services.QueryLimit(values)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		cells := geohash.Cover(geohash.RadiusBox(lat, lon, radius), maxCells)
+		houses, err := searcher.GetHousesNearby(ctx, lat, lon, radius, cells, limit)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get houses", http.StatusInternalServerError, requestId, err)
+			return
+		}
+		render.JSON(w, r, &NearbyResponse{Houses: houses})
+	}
+}
+
+func InBox(ctx context.Context, log *slog.Logger, searcher geoSearcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.house.inBox"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		values := r.URL.Query()
+		box, err := parseBox(values)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+		limit, err := services.QueryLimit(values)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		houses, err := searcher.GetHousesInBox(ctx, box, geohash.Cover(box, maxCells), limit)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get houses", http.StatusInternalServerError, requestId, err)
+			return
+		}
+		render.JSON(w, r, &GetHousesResponse{Houses: houses})
+	}
+}
+
+func parsePoint(values url.Values) (float64, float64, error) {
+	lat, latOk, err := services.QueryFloat(values, "lat")
+	if err != nil {
+		return 0, 0, err
+	}
+	lon, lonOk, err := services.QueryFloat(values, "lon")
+	if err != nil {
+		return 0, 0, err
+	}
+	if !latOk || !lonOk || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("invalid lat or lon parameter")
+	}
+	return lat, lon, nil
+}
+
+func parseBox(values url.Values) (geohash.Box, error) {
+	var box geohash.Box
+	for key, dest := range map[string]*float64{
+		"min_lat": &box.MinLat,
+		"min_lon": &box.MinLon,
+		"max_lat": &box.MaxLat,
+		"max_lon": &box.MaxLon,
+	} {
+		value, ok, err := services.QueryFloat(values, key)
+		if err != nil {
+			return box, err
+		}
+		if !ok {
+			return box, fmt.Errorf("%s parameter is required", key)
+		}
+		*dest = value
+	}
+	if !box.Valid() {
+		return box, fmt.Errorf("invalid bounding box")
+	}
+	return box, nil
+}
@@ -0,0 +1,106 @@
+package house
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type getHouses interface {
+	GetHouses(ctx context.Context, filter structures.HouseFilter) (*[]structures.House, error)
+}
+
+type GetHousesResponse struct {
+	Houses     *[]structures.House `json:"houses"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+func GetHouses(ctx context.Context, log *slog.Logger, source getHouses) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.house.getHouses"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		filter, err := parseHouseFilter(r.URL.Query())
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+		limit := filter.Limit
+		// one extra row tells whether there is a next page
+		filter.Limit++
+
+		houses, err := source.GetHouses(ctx, filter)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get houses", http.StatusInternalServerError, requestId, err)
+			return
+		}
+
+		resp := GetHousesResponse{Houses: houses}
+		if len(*houses) > limit {
+			*houses = (*houses)[:limit]
+			last := (*houses)[limit-1]
+			value := last.CreatedAt
+			if filter.SortBy == "update_at" {
+				value = last.UpdateAt
+			}
+			resp.NextCursor = services.EncodeCursor(
+				structures.Cursor{Value: value.Format(time.RFC3339Nano), Id: last.Id},
+			)
+		}
+		render.JSON(w, r, &resp)
+	}
+}
+
+func parseHouseFilter(values url.Values) (structures.HouseFilter, error) {
+	var filter structures.HouseFilter
+	var err error
+
+	filter.Address = values.Get("address")
+	filter.Developer = values.Get("developer")
+	if filter.YearFrom, err = services.QueryInt(values, "year_from"); err != nil {
+		return filter, err
+	}
+	if filter.YearTo, err = services.QueryInt(values, "year_to"); err != nil {
+		return filter, err
+	}
+	if filter.HasApproved, err = services.QueryBool(values, "has_approved"); err != nil {
+		return filter, err
+	}
+
+	filter.SortBy = values.Get("sort")
+	switch filter.SortBy {
+	case "":
+		filter.SortBy = "created_at"
+	case "created_at", "update_at":
+	default:
+		return filter, fmt.Errorf("invalid sort parameter")
+	}
+	if filter.Desc, err = services.QueryOrder(values, true); err != nil {
+		return filter, err
+	}
+	if filter.Limit, err = services.QueryLimit(values); err != nil {
+		return filter, err
+	}
+
+	if str := values.Get("cursor"); str != "" {
+		if filter.After, err = services.DecodeCursor(str); err != nil {
+			return filter, err
+		}
+		if _, err = time.Parse(time.RFC3339Nano, filter.After.Value); err != nil {
+			return filter, fmt.Errorf("invalid cursor")
+		}
+	}
+	return filter, nil
+}
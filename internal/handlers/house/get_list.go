@@ -2,7 +2,6 @@ package house
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -13,19 +12,15 @@ import (
 	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/render"
 )
 
 const moderatorType = "moderator"
 
 type getList interface {
 	GetHouse(ctx context.Context, id int) (*structures.House, error)
-	GetListByClient(ctx context.Context, id int) (*[]structures.Flat, error)
-	GetListByModerator(ctx context.Context, id int) (*[]structures.Flat, error)
-}
-
-type GetListResponse struct {
-	Flats *[]structures.Flat `json:"flats"`
+	// GetListPage returns the serialized list of the flats, the limit of the
+	// filter includes the extra row telling whether there is a next page.
+	GetListPage(ctx context.Context, id int, filter structures.FlatFilter, moderator bool) (*services.Page, error)
 }
 
 func GetList(ctx context.Context, log *slog.Logger, getListFlats getList) http.HandlerFunc {
@@ -60,24 +55,44 @@ func GetList(ctx context.Context, log *slog.Logger, getListFlats getList) http.H
 
 		header := r.Header.Get("Authorization")
 		token := strings.Split(header, " ")[1]
-
-		var flats *[]structures.Flat
 		utype := auth.GetUserType(token)
-		if utype == moderatorType {
-			list, e := getListFlats.GetListByModerator(ctx, id)
-			err = e
-			flats = list
-		} else {
-			list, e := getListFlats.GetListByClient(ctx, id)
-			err = e
-			flats = list
+
+		filter, err := services.ParseFlatFilter(r.URL.Query(), utype == moderatorType)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
 		}
+		// one extra row tells whether there is a next page
+		filter.Limit++
+
+		page, err := getListFlats.GetListPage(ctx, id, filter, utype == moderatorType)
 		if err != nil {
 			services.MakeErrorResponse(w, r, log, "failed to get flats", http.StatusBadRequest, requestId, err)
 			return
 		}
-		fmt.Println("flats", flats)
-		listResponse := GetListResponse{Flats: flats}
-		render.JSON(w, r, &listResponse)
+
+		// the list changes at any moment, so the client revalidates it with
+		// the ETag on every request
+		w.Header().Set("ETag", page.ETag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if matchesETag(r.Header.Get("If-None-Match"), page.ETag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(page.Body)
+	}
+}
+
+// matchesETag reports whether the If-None-Match header lists the ETag, the
+// weak comparison is used as RFC 9110 requires for If-None-Match.
+func matchesETag(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
 	}
+	return false
 }
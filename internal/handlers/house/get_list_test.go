@@ -0,0 +1,81 @@
+package house
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type fakeList struct {
+	page *services.Page
+}
+
+func (f fakeList) GetHouse(_ context.Context, id int) (*structures.House, error) {
+	return &structures.House{Id: id}, nil
+}
+
+func (f fakeList) GetListPage(context.Context, int, structures.FlatFilter, bool) (*services.Page, error) {
+	return f.page, nil
+}
+
+func getHouse(t *testing.T, handler http.Handler, ifNoneMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := auth.BuildJWTString("client", uuid.New())
+	if err != nil {
+		t.Fatalf("failed to build token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/house/1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGetListRevalidatesWithETag(t *testing.T) {
+	flats := []structures.Flat{{Id: 1, HouseId: 1, Price: 1000, Rooms: 2, Status: "approved"}}
+	page, err := services.NewPage(&flats, structures.FlatFilter{Limit: 21})
+	if err != nil {
+		t.Fatalf("NewPage: %v", err)
+	}
+	router := chi.NewRouter()
+	router.Get(
+		"/house/{id}",
+		GetList(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), fakeList{page: page}),
+	)
+
+	rec := getHouse(t, router, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag != page.ETag || rec.Body.String() != string(page.Body) {
+		t.Fatalf("response = %s %s, want the cached page", etag, rec.Body.String())
+	}
+	if rec.Header().Get("Cache-Control") == "" {
+		t.Error("Cache-Control is not set")
+	}
+
+	for _, header := range []string{etag, `"other", ` + etag, "W/" + etag, "*"} {
+		rec = getHouse(t, router, header)
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: status = %d, body = %q, want 304", header, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = getHouse(t, router, `"other"`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("stale If-None-Match: status = %d, want 200", rec.Code)
+	}
+}
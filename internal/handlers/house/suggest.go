@@ -0,0 +1,60 @@
+package house
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const minSuggestLength = 2
+
+type addressSuggester interface {
+	SuggestAddresses(ctx context.Context, text string, limit int) (*[]structures.AddressSuggestion, error)
+}
+
+type SuggestResponse struct {
+	Suggestions *[]structures.AddressSuggestion `json:"suggestions"`
+}
+
+func Suggest(ctx context.Context, log *slog.Logger, suggester addressSuggester) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.house.suggest"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		text := strings.TrimSpace(r.URL.Query().Get("q"))
+		if len([]rune(text)) < minSuggestLength {
+			services.MakeErrorResponse(w, r, log, "query is too short", http.StatusBadRequest, requestId, nil)
+			return
+		}
+		limit, err := services.QueryLimit(r.URL.Query())
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		suggestions, err := suggester.SuggestAddresses(ctx, text, limit)
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to suggest addresses",
+				http.StatusInternalServerError,
+				requestId,
+				err,
+			)
+			return
+		}
+		render.JSON(w, r, &SuggestResponse{Suggestions: suggestions})
+	}
+}
@@ -0,0 +1,67 @@
+package moderation
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type duplicateClusters interface {
+	GetDuplicateClusters(ctx context.Context, afterId, limit int) (*[]structures.DuplicateCluster, error)
+}
+
+type DuplicatesResponse struct {
+	Clusters   *[]structures.DuplicateCluster `json:"clusters"`
+	NextCursor string                         `json:"next_cursor,omitempty"`
+}
+
+// Duplicates lists clusters of likely duplicate listings, the cursor is the
+// id of the last original flat of the previous page.
+func Duplicates(ctx context.Context, log *slog.Logger, source duplicateClusters) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.moderation.duplicates"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		values := r.URL.Query()
+		limit, err := services.QueryLimit(values)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+		afterId, err := services.QueryInt(values, "cursor")
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		clusters, err := source.GetDuplicateClusters(ctx, afterId, limit)
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to get duplicate clusters",
+				http.StatusInternalServerError,
+				requestId,
+				err,
+			)
+			return
+		}
+
+		resp := DuplicatesResponse{Clusters: clusters}
+		if len(*clusters) == limit {
+			resp.NextCursor = strconv.Itoa((*clusters)[limit-1].Original.Id)
+		}
+		render.JSON(w, r, &resp)
+	}
+}
@@ -0,0 +1,73 @@
+package moderation
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/templates"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type emailRenderer interface {
+	Render(name, locale string, data interface{}, unsubscribeURL string) (*templates.Email, error)
+}
+
+// previewUnsubscribeURL shows the footer of the subscription emails.
+const previewUnsubscribeURL = "https://example.com/unsubscribe?token=preview"
+
+// PreviewEmail renders the email template with sample data. The locale
+// query parameter selects the variant, format=html or format=text returns
+// the body as is, otherwise the subject and both bodies are returned.
+func PreviewEmail(log *slog.Logger, renderer emailRenderer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.moderation.previewEmail"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		name := chi.URLParam(r, "name")
+		data, ok := templates.Sample(name)
+		if !ok {
+			services.MakeErrorResponse(
+				w, r, log, "unknown email template", http.StatusNotFound, requestId, templates.ErrUnknownTemplate,
+			)
+			return
+		}
+
+		locale := r.URL.Query().Get("locale")
+		if locale == "" {
+			locale = templates.DefaultLocale
+		}
+		if locale != templates.LocaleRu && locale != templates.LocaleEn {
+			services.MakeErrorResponse(
+				w, r, log, "invalid locale", http.StatusBadRequest, requestId, errors.New("unsupported locale"),
+			)
+			return
+		}
+
+		email, err := renderer.Render(name, locale, data, previewUnsubscribeURL)
+		if err != nil {
+			services.MakeErrorResponse(
+				w, r, log, "failed to render email", http.StatusInternalServerError, requestId, err,
+			)
+			return
+		}
+
+		switch r.URL.Query().Get("format") {
+		case "html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte(email.HTML))
+		case "text":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte(email.Text))
+		default:
+			render.JSON(w, r, email)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package moderation
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/notifications"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type notificationMetrics interface {
+	Metrics(ctx context.Context) (*notifications.Metrics, error)
+}
+
+// Notifications shows the email delivery outcomes and the queue state.
+func Notifications(ctx context.Context, log *slog.Logger, queue notificationMetrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.moderation.notifications"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		metrics, err := queue.Metrics(ctx)
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to get notification metrics",
+				http.StatusInternalServerError,
+				requestId,
+				err,
+			)
+			return
+		}
+
+		render.JSON(w, r, metrics)
+	}
+}
@@ -0,0 +1,234 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	actionClaim   = "claim"
+	actionRelease = "release"
+
+	writeWait    = 10 * time.Second
+	pongWait     = 60 * time.Second
+	pingInterval = pongWait * 9 / 10
+	maxMessage   = 4096
+)
+
+type claims interface {
+	ClaimFlat(ctx context.Context, id int, moderatorId uuid.UUID) (*structures.Flat, error)
+	ReleaseFlat(ctx context.Context, id int, moderatorId uuid.UUID) (*structures.Flat, error)
+}
+
+type publisher interface {
+	Publish(event events.Event)
+}
+
+// command is sent by the dashboard to claim or release a flat.
+type command struct {
+	Action string `json:"action"`
+	FlatId int    `json:"flat_id"`
+}
+
+// result answers a command, the queue changes themselves arrive as events.
+type result struct {
+	Type   string `json:"type"`
+	Action string `json:"action"`
+	FlatId int    `json:"flat_id"`
+	Ok     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// Queue is the WebSocket of the moderation dashboard. It pushes the changes
+// of the moderation queue and lets the moderator claim and release flats,
+// the flats still claimed when the connection closes are released.
+func Queue(ctx context.Context, log *slog.Logger, source claims, hub *events.Hub, bus publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.moderation.queue"
+		requestId := middleware.GetReqID(r.Context())
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+		moderatorId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has already answered the request
+			log.Error("failed to upgrade connection", slog.Any("error", err))
+			return
+		}
+		defer conn.Close()
+
+		sub := hub.SubscribeAll()
+		defer sub.Close()
+
+		s := &queueSession{
+			ctx:         ctx,
+			log:         log,
+			source:      source,
+			bus:         bus,
+			conn:        conn,
+			moderatorId: moderatorId,
+			claimed:     make(map[int]bool),
+			results:     make(chan result),
+			stop:        make(chan struct{}),
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.read()
+		}()
+
+		s.write(sub.Events, done)
+		close(s.stop)
+		conn.Close()
+		<-done
+	}
+}
+
+type queueSession struct {
+	ctx         context.Context
+	log         *slog.Logger
+	source      claims
+	bus         publisher
+	conn        *websocket.Conn
+	moderatorId uuid.UUID
+	// claimed is owned by the read loop
+	claimed map[int]bool
+	results chan result
+	stop    chan struct{}
+}
+
+// write owns the writes to the connection, it returns when the read loop
+// is done or a write fails.
+func (s *queueSession) write(queue <-chan events.Event, done <-chan struct{}) {
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case event := <-queue:
+			err = s.writeJSON(event)
+		case res := <-s.results:
+			err = s.writeJSON(res)
+		case <-ping.C:
+			_ = s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err = s.conn.WriteMessage(websocket.PingMessage, nil)
+		}
+		if err != nil {
+			s.log.Info("moderation queue connection closed", slog.Any("error", err))
+			return
+		}
+	}
+}
+
+func (s *queueSession) writeJSON(v interface{}) error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return s.conn.WriteJSON(v)
+}
+
+// read handles the commands until the connection closes, then releases the
+// flats claimed over it.
+func (s *queueSession) read() {
+	defer s.releaseAll()
+
+	s.conn.SetReadLimit(maxMessage)
+	_ = s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(
+		func(string) error {
+			return s.conn.SetReadDeadline(time.Now().Add(pongWait))
+		},
+	)
+
+	for {
+		var cmd command
+		if err := s.conn.ReadJSON(&cmd); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				s.log.Info("failed to read command", slog.Any("error", err))
+			}
+			return
+		}
+
+		res := s.handle(cmd)
+		select {
+		case s.results <- res:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *queueSession) handle(cmd command) result {
+	res := result{Type: "result", Action: cmd.Action, FlatId: cmd.FlatId}
+
+	var flat *structures.Flat
+	var previous string
+	var err error
+	switch cmd.Action {
+	case actionClaim:
+		previous = "created"
+		flat, err = s.source.ClaimFlat(s.ctx, cmd.FlatId, s.moderatorId)
+	case actionRelease:
+		previous = "on moderation"
+		flat, err = s.source.ReleaseFlat(s.ctx, cmd.FlatId, s.moderatorId)
+	default:
+		res.Error = "unknown action"
+		return res
+	}
+	if errors.Is(err, structures.ErrNotClaimable) {
+		res.Error = err.Error()
+		return res
+	}
+	if err != nil {
+		res.Error = "internal error"
+		return res
+	}
+
+	if cmd.Action == actionClaim {
+		s.claimed[flat.Id] = true
+	} else {
+		delete(s.claimed, flat.Id)
+	}
+	for _, event := range events.StatusEvents(*flat, previous) {
+		s.bus.Publish(event)
+	}
+	res.Ok = true
+	return res
+}
+
+func (s *queueSession) releaseAll() {
+	for id := range s.claimed {
+		flat, err := s.source.ReleaseFlat(s.ctx, id, s.moderatorId)
+		if errors.Is(err, structures.ErrNotClaimable) {
+			// already decided or released over HTTP
+			continue
+		}
+		if err != nil {
+			s.log.Error("failed to release flat", slog.Int("flat_id", id), slog.Any("error", err))
+			continue
+		}
+		for _, event := range events.StatusEvents(*flat, "on moderation") {
+			s.bus.Publish(event)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+	"github.com/google/uuid"
+)
+
+// fakeClaims keeps the flats in memory and claims them the way the storage
+// does.
+type fakeClaims struct {
+	flats map[int]*structures.Flat
+	err   error
+}
+
+func (f *fakeClaims) ClaimFlat(_ context.Context, id int, moderatorId uuid.UUID) (*structures.Flat, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	flat, ok := f.flats[id]
+	if !ok || flat.Status != "created" {
+		return nil, structures.ErrNotClaimable
+	}
+	flat.Status, flat.ModeratorId = "on moderation", &moderatorId
+	result := *flat
+	return &result, nil
+}
+
+func (f *fakeClaims) ReleaseFlat(_ context.Context, id int, moderatorId uuid.UUID) (*structures.Flat, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	flat, ok := f.flats[id]
+	if !ok || flat.Status != "on moderation" || *flat.ModeratorId != moderatorId {
+		return nil, structures.ErrNotClaimable
+	}
+	flat.Status, flat.ModeratorId = "created", nil
+	result := *flat
+	return &result, nil
+}
+
+type fakeBus struct {
+	published []events.Type
+}
+
+func (b *fakeBus) Publish(event events.Event) {
+	b.published = append(b.published, event.Type)
+}
+
+func newTestSession(source claims, bus publisher, moderatorId uuid.UUID) *queueSession {
+	return &queueSession{
+		ctx:         context.Background(),
+		log:         slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		source:      source,
+		bus:         bus,
+		moderatorId: moderatorId,
+		claimed:     make(map[int]bool),
+	}
+}
+
+func TestHandleClaimAndRelease(t *testing.T) {
+	source := &fakeClaims{flats: map[int]*structures.Flat{1: {Id: 1, HouseId: 1, Status: "created"}}}
+	bus := &fakeBus{}
+	s := newTestSession(source, bus, uuid.New())
+
+	res := s.handle(command{Action: actionClaim, FlatId: 1})
+	if !res.Ok || res.Error != "" {
+		t.Fatalf("claim result = %+v, want ok", res)
+	}
+	if !s.claimed[1] {
+		t.Error("claimed flat is not tracked by the session")
+	}
+	if len(bus.published) != 2 || bus.published[1] != events.FlatClaimed {
+		t.Errorf("published after claim = %v, want status change and claim", bus.published)
+	}
+
+	bus.published = nil
+	res = s.handle(command{Action: actionRelease, FlatId: 1})
+	if !res.Ok || res.Error != "" {
+		t.Fatalf("release result = %+v, want ok", res)
+	}
+	if s.claimed[1] {
+		t.Error("released flat is still tracked by the session")
+	}
+	if len(bus.published) != 2 || bus.published[1] != events.FlatReleased {
+		t.Errorf("published after release = %v, want status change and release", bus.published)
+	}
+}
+
+func TestHandleRejectsClaimedFlat(t *testing.T) {
+	other := uuid.New()
+	source := &fakeClaims{flats: map[int]*structures.Flat{
+		1: {Id: 1, HouseId: 1, Status: "on moderation", ModeratorId: &other},
+	}}
+	bus := &fakeBus{}
+	s := newTestSession(source, bus, uuid.New())
+
+	for _, action := range []string{actionClaim, actionRelease} {
+		res := s.handle(command{Action: action, FlatId: 1})
+		if res.Ok || res.Error != structures.ErrNotClaimable.Error() {
+			t.Errorf("%s result = %+v, want %q", action, res, structures.ErrNotClaimable)
+		}
+	}
+	if len(s.claimed) != 0 || len(bus.published) != 0 {
+		t.Errorf("claimed = %v, published = %v, want nothing", s.claimed, bus.published)
+	}
+}
+
+func TestHandleErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		cmd    command
+		err    error
+		result string
+	}{
+		{"unknown action", command{Action: "approve", FlatId: 1}, nil, "unknown action"},
+		{"storage error", command{Action: actionClaim, FlatId: 1}, errors.New("connection refused"), "internal error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeClaims{flats: map[int]*structures.Flat{1: {Id: 1, Status: "created"}}, err: tt.err}
+			bus := &fakeBus{}
+			res := newTestSession(source, bus, uuid.New()).handle(tt.cmd)
+			if res.Ok || res.Error != tt.result {
+				t.Errorf("result = %+v, want error %q", res, tt.result)
+			}
+			if len(bus.published) != 0 {
+				t.Errorf("published = %v, want nothing", bus.published)
+			}
+		})
+	}
+}
+
+func TestReleaseAll(t *testing.T) {
+	moderatorId := uuid.New()
+	source := &fakeClaims{flats: map[int]*structures.Flat{
+		1: {Id: 1, HouseId: 1, Status: "created"},
+		2: {Id: 2, HouseId: 1, Status: "created"},
+	}}
+	bus := &fakeBus{}
+	s := newTestSession(source, bus, moderatorId)
+	s.handle(command{Action: actionClaim, FlatId: 1})
+	s.handle(command{Action: actionClaim, FlatId: 2})
+	// the second flat is decided over HTTP meanwhile
+	source.flats[2].Status, source.flats[2].ModeratorId = "approved", nil
+
+	s.releaseAll()
+
+	if source.flats[1].Status != "created" || source.flats[2].Status != "approved" {
+		t.Errorf("statuses = %s, %s, want created, approved", source.flats[1].Status, source.flats[2].Status)
+	}
+}
@@ -0,0 +1,26 @@
+package moderation
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/moderation"
+	"github.com/go-chi/render"
+)
+
+type rulesStats interface {
+	Stats() []moderation.RuleStats
+}
+
+type RulesResponse struct {
+	Rules []moderation.RuleStats `json:"rules"`
+}
+
+// Rules shows the pre-moderation rules with their hit statistics since the
+// service start.
+func Rules(log *slog.Logger, engine rulesStats) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Info("moderation rules requested")
+		render.JSON(w, r, &RulesResponse{Rules: engine.Stats()})
+	}
+}
@@ -0,0 +1,147 @@
+package preferences
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/response"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// preferencesRequest changes the given preferences, at least one of them
+// is required.
+type preferencesRequest struct {
+	Delivery string `json:"delivery" validate:"required_without=Locale,omitempty,oneof=instant hourly daily muted"`
+	Locale   string `json:"locale" validate:"required_without=Delivery,omitempty,oneof=ru en"`
+}
+
+type Response struct {
+	Delivery string `json:"delivery"`
+	Locale   string `json:"locale,omitempty"`
+}
+
+type deliveryStore interface {
+	SetDelivery(ctx context.Context, userId uuid.UUID, delivery string) (bool, error)
+}
+
+type preferencesStore interface {
+	deliveryStore
+	GetPreferences(ctx context.Context, userId uuid.UUID) (*structures.Preferences, error)
+	SetLocale(ctx context.Context, userId uuid.UUID, locale string) (bool, error)
+}
+
+// Get returns the notification delivery preference and the email locale of
+// the user.
+func Get(ctx context.Context, log *slog.Logger, store preferencesStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.preferences.get"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		preferences, err := store.GetPreferences(ctx, userId)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to find user", http.StatusNotFound, requestId, err)
+			return
+		}
+
+		render.JSON(w, r, &Response{Delivery: preferences.Delivery, Locale: preferences.Locale})
+	}
+}
+
+// Update changes the notification delivery preference and the email locale
+// of the user.
+func Update(ctx context.Context, log *slog.Logger, store preferencesStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req preferencesRequest
+		var err error
+		const op = "handlers.preferences.update"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		// decode
+		err = render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			services.MakeErrorResponse(w, r, log, "request body is empty", http.StatusBadRequest, requestId, err)
+			return
+		}
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to decode request body",
+				http.StatusBadRequest,
+				requestId,
+				err,
+			)
+			return
+		}
+		log.Info("request body decoded")
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("Invalid request")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(validateErr, requestId))
+			return
+		}
+
+		if req.Delivery != "" {
+			found, err := store.SetDelivery(ctx, userId, req.Delivery)
+			if err != nil {
+				services.MakeErrorResponse(w, r, log, "failed to update delivery", http.StatusInternalServerError, requestId, err)
+				return
+			}
+			if !found {
+				services.MakeErrorResponse(w, r, log, "failed to find user", http.StatusNotFound, requestId, nil)
+				return
+			}
+		}
+		if req.Locale != "" {
+			found, err := store.SetLocale(ctx, userId, req.Locale)
+			if err != nil {
+				services.MakeErrorResponse(w, r, log, "failed to update locale", http.StatusInternalServerError, requestId, err)
+				return
+			}
+			if !found {
+				services.MakeErrorResponse(w, r, log, "failed to find user", http.StatusNotFound, requestId, nil)
+				return
+			}
+		}
+
+		preferences, err := store.GetPreferences(ctx, userId)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to find user", http.StatusNotFound, requestId, err)
+			return
+		}
+
+		render.JSON(w, r, &Response{Delivery: preferences.Delivery, Locale: preferences.Locale})
+	}
+}
@@ -0,0 +1,107 @@
+package preferences
+
+import (
+	"context"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type tokenVerifier interface {
+	Verify(token string) (uuid.UUID, error)
+}
+
+// unsubscribePage asks to confirm the unsubscribe, mail scanners and link
+// previews follow the links of an email with GET and must not mute the user.
+var unsubscribePage = template.Must(
+	template.New("unsubscribe").Parse(
+		`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+{{if .Done}}<p>You will not receive notifications anymore.</p>
+{{else}}<form method="post" action="/unsubscribe?token={{.Token}}">
+<p>Stop receiving notifications about flats?</p>
+<button type="submit">Unsubscribe</button>
+</form>
+{{end}}</body>
+</html>
+`,
+	),
+)
+
+type unsubscribeView struct {
+	Token string
+	Done  bool
+}
+
+// ConfirmUnsubscribe shows the unsubscribe confirmation for the signed
+// token of an email link, the user is muted only by the POST request.
+func ConfirmUnsubscribe(log *slog.Logger, tokens tokenVerifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.preferences.confirmUnsubscribe"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		token := r.URL.Query().Get("token")
+		if _, err := tokens.Verify(token); err != nil {
+			services.MakeErrorResponse(w, r, log, "invalid token", http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := unsubscribePage.Execute(w, unsubscribeView{Token: token}); err != nil {
+			log.Error("failed to render unsubscribe page", slog.Any("error", err))
+		}
+	}
+}
+
+// Unsubscribe mutes the notifications of the user from the signed token of
+// an email link, it does not require a login. It serves the confirmation
+// form and the one-click unsubscribe of the mail clients.
+func Unsubscribe(ctx context.Context, log *slog.Logger, store deliveryStore, tokens tokenVerifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.preferences.unsubscribe"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, err := tokens.Verify(r.URL.Query().Get("token"))
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "invalid token", http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		found, err := store.SetDelivery(ctx, userId, structures.DeliveryMuted)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to update delivery", http.StatusInternalServerError, requestId, err)
+			return
+		}
+		if !found {
+			services.MakeErrorResponse(w, r, log, "failed to find user", http.StatusNotFound, requestId, nil)
+			return
+		}
+
+		// the confirmation form is submitted by a browser
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err = unsubscribePage.Execute(w, unsubscribeView{Done: true}); err != nil {
+				log.Error("failed to render unsubscribe page", slog.Any("error", err))
+			}
+			return
+		}
+		render.JSON(w, r, &Response{Delivery: structures.DeliveryMuted})
+	}
+}
@@ -0,0 +1,184 @@
+package savedsearch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/response"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type searchRequest struct {
+	PriceFrom int    `json:"price_from" validate:"min=0"`
+	PriceTo   int    `json:"price_to" validate:"omitempty,min=0,gtefield=PriceFrom"`
+	RoomsFrom int    `json:"rooms_from" validate:"min=0"`
+	RoomsTo   int    `json:"rooms_to" validate:"omitempty,min=0,gtefield=RoomsFrom"`
+	Developer string `json:"developer" validate:"max=200"`
+	Address   string `json:"address" validate:"max=200"`
+}
+
+type savedSearches interface {
+	SaveSearch(ctx context.Context, search structures.SavedSearch) (*structures.SavedSearch, error)
+	GetSavedSearches(ctx context.Context, userId uuid.UUID) (*[]structures.SavedSearch, error)
+	DeleteSavedSearch(ctx context.Context, userId uuid.UUID, id int) (bool, error)
+}
+
+type ListResponse struct {
+	Searches *[]structures.SavedSearch `json:"searches"`
+}
+
+// Create saves the search criteria of the user, the user is alerted when an
+// approved flat matches them.
+func Create(ctx context.Context, log *slog.Logger, source savedSearches) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req searchRequest
+		var err error
+		const op = "handlers.savedsearch.create"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		// decode
+		err = render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			services.MakeErrorResponse(w, r, log, "request body is empty", http.StatusBadRequest, requestId, err)
+			return
+		}
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to decode request body",
+				http.StatusBadRequest,
+				requestId,
+				err,
+			)
+			return
+		}
+		log.Info("request body decoded")
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("Invalid request")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(validateErr, requestId))
+			return
+		}
+
+		search, err := source.SaveSearch(
+			ctx, structures.SavedSearch{
+				UserId:    userId,
+				PriceFrom: req.PriceFrom,
+				PriceTo:   req.PriceTo,
+				RoomsFrom: req.RoomsFrom,
+				RoomsTo:   req.RoomsTo,
+				Developer: strings.TrimSpace(req.Developer),
+				Address:   strings.TrimSpace(req.Address),
+			},
+		)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to save search", http.StatusInternalServerError, requestId, err)
+			return
+		}
+
+		render.JSON(w, r, &search)
+	}
+}
+
+// List returns the saved searches of the user.
+func List(ctx context.Context, log *slog.Logger, source savedSearches) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.savedsearch.list"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		searches, err := source.GetSavedSearches(ctx, userId)
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to get saved searches",
+				http.StatusInternalServerError,
+				requestId,
+				err,
+			)
+			return
+		}
+
+		render.JSON(w, r, &ListResponse{Searches: searches})
+	}
+}
+
+// Delete removes a saved search of the user.
+func Delete(ctx context.Context, log *slog.Logger, source savedSearches) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.savedsearch.delete"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get id from url param", http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		found, err := source.DeleteSavedSearch(ctx, userId, id)
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to delete saved search",
+				http.StatusInternalServerError,
+				requestId,
+				err,
+			)
+			return
+		}
+		if !found {
+			services.MakeErrorResponse(w, r, log, "saved search not found", http.StatusNotFound, requestId, nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,245 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/handlers/auth"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/services"
+	hooks "github.com/dugtriol/backend-bootcamp-assignment-2024/internal/webhooks"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/pkg/response"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type webhookRequest struct {
+	Url    string   `json:"url" validate:"required,url,startswith=http"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=flat.created flat.approved flat.declined"`
+	Secret string   `json:"secret" validate:"required,min=16,max=200"`
+}
+
+type webhooks interface {
+	SaveWebhook(ctx context.Context, webhook structures.Webhook) (*structures.Webhook, error)
+	GetWebhook(ctx context.Context, id int) (*structures.Webhook, error)
+	GetWebhooks(ctx context.Context, ownerId uuid.UUID) (*[]structures.Webhook, error)
+	DeleteWebhook(ctx context.Context, ownerId uuid.UUID, id int) (bool, error)
+	GetWebhookDeliveries(ctx context.Context, webhookId, beforeId, limit int) (*[]structures.WebhookDelivery, error)
+}
+
+type ListResponse struct {
+	Webhooks *[]structures.Webhook `json:"webhooks"`
+}
+
+type DeliveriesResponse struct {
+	Deliveries *[]structures.WebhookDelivery `json:"deliveries"`
+	NextCursor string                        `json:"next_cursor,omitempty"`
+}
+
+// Create subscribes the url to the listing events, the payloads are signed
+// with the secret.
+func Create(ctx context.Context, log *slog.Logger, source webhooks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req webhookRequest
+		var err error
+		const op = "handlers.webhook.create"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		// decode
+		err = render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			services.MakeErrorResponse(w, r, log, "request body is empty", http.StatusBadRequest, requestId, err)
+			return
+		}
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to decode request body",
+				http.StatusBadRequest,
+				requestId,
+				err,
+			)
+			return
+		}
+		log.Info("request body decoded")
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("Invalid request")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(validateErr, requestId))
+			return
+		}
+
+		// the deliveries are made from the internal network of the service
+		if err = hooks.CheckURL(r.Context(), req.Url); err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"webhook url must resolve to a public address",
+				http.StatusBadRequest,
+				requestId,
+				err,
+			)
+			return
+		}
+
+		webhook, err := source.SaveWebhook(
+			ctx, structures.Webhook{
+				OwnerId: userId,
+				Url:     req.Url,
+				Events:  req.Events,
+				Secret:  req.Secret,
+			},
+		)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to save webhook", http.StatusInternalServerError, requestId, err)
+			return
+		}
+
+		render.JSON(w, r, &webhook)
+	}
+}
+
+// List returns the webhooks of the user.
+func List(ctx context.Context, log *slog.Logger, source webhooks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.list"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		list, err := source.GetWebhooks(ctx, userId)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get webhooks", http.StatusInternalServerError, requestId, err)
+			return
+		}
+
+		render.JSON(w, r, &ListResponse{Webhooks: list})
+	}
+}
+
+// Delete removes the webhook of the user with its delivery log.
+func Delete(ctx context.Context, log *slog.Logger, source webhooks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.delete"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get id from url param", http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		found, err := source.DeleteWebhook(ctx, userId, id)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to delete webhook", http.StatusInternalServerError, requestId, err)
+			return
+		}
+		if !found {
+			services.MakeErrorResponse(w, r, log, "webhook not found", http.StatusNotFound, requestId, nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Deliveries returns the delivery log of the webhook, newest first. The
+// cursor is the id of the last delivery of the previous page.
+func Deliveries(ctx context.Context, log *slog.Logger, source webhooks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.deliveries"
+		requestId := middleware.GetReqID(r.Context())
+		log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestId),
+		)
+
+		userId, ok := auth.RequireUserId(w, r, log, requestId)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, "failed to get id from url param", http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		values := r.URL.Query()
+		limit, err := services.QueryLimit(values)
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+		beforeId, err := services.QueryInt(values, "cursor")
+		if err != nil {
+			services.MakeErrorResponse(w, r, log, err.Error(), http.StatusBadRequest, requestId, err)
+			return
+		}
+
+		webhook, err := source.GetWebhook(ctx, id)
+		if err != nil || webhook.OwnerId != userId {
+			services.MakeErrorResponse(w, r, log, "webhook not found", http.StatusNotFound, requestId, err)
+			return
+		}
+
+		deliveries, err := source.GetWebhookDeliveries(ctx, id, beforeId, limit)
+		if err != nil {
+			services.MakeErrorResponse(
+				w,
+				r,
+				log,
+				"failed to get webhook deliveries",
+				http.StatusInternalServerError,
+				requestId,
+				err,
+			)
+			return
+		}
+
+		resp := DeliveriesResponse{Deliveries: deliveries}
+		if len(*deliveries) == limit {
+			resp.NextCursor = strconv.Itoa((*deliveries)[limit-1].Id)
+		}
+		render.JSON(w, r, &resp)
+	}
+}
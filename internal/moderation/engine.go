@@ -0,0 +1,148 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"gopkg.in/yaml.v3"
+)
+
+type duplicateFinder interface {
+	FindDuplicateFlats(ctx context.Context, flat structures.Flat, priceFrom, priceTo int) (*[]structures.Flat, error)
+}
+
+// Decision is the outcome of the automatic pre-moderation.
+type Decision struct {
+	Action Action   `json:"action"`
+	Reason string   `json:"reason,omitempty"`
+	Rules  []string `json:"rules,omitempty"`
+	// DuplicateOf is the original flat found by a broken duplicate rule.
+	DuplicateOf *int `json:"duplicate_of,omitempty"`
+}
+
+type RuleStats struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Action      Action `json:"action"`
+	Evaluations int64  `json:"evaluations"`
+	Hits        int64  `json:"hits"`
+	Errors      int64  `json:"errors"`
+}
+
+type loadedRule struct {
+	cfg         RuleConfig
+	rule        rule
+	evaluations atomic.Int64
+	hits        atomic.Int64
+	errors      atomic.Int64
+}
+
+// Engine evaluates the configured rules against new and edited flats.
+// Statistics are kept in memory and reset on restart.
+type Engine struct {
+	rules []*loadedRule
+	store duplicateFinder
+	log   *slog.Logger
+}
+
+// Load reads the rules from the YAML file, a missing file means no rules.
+func Load(path string, store duplicateFinder, log *slog.Logger) (*Engine, error) {
+	engine := &Engine{store: store, log: log}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Warn("moderation rules file not found, automatic moderation is disabled", slog.String("path", path))
+		return engine, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read moderation rules: %w", err)
+	}
+
+	var cfg Config
+	if err = yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse moderation rules: %w", err)
+	}
+
+	for _, ruleCfg := range cfg.Rules {
+		if _, ok := severity[ruleCfg.Action]; !ok {
+			return nil, fmt.Errorf("rule %s: unknown action %q", ruleCfg.Name, ruleCfg.Action)
+		}
+		r, err := newRule(ruleCfg, store)
+		if err != nil {
+			return nil, err
+		}
+		engine.rules = append(engine.rules, &loadedRule{cfg: ruleCfg, rule: r})
+	}
+	return engine, nil
+}
+
+// Evaluate runs every rule, the most severe action of the broken rules wins.
+// A failing rule is skipped so that moderation falls back to manual.
+func (e *Engine) Evaluate(ctx context.Context, flat structures.Flat) Decision {
+	decision := Decision{Action: ActionPass}
+	for _, r := range e.rules {
+		r.evaluations.Add(1)
+		var details Decision
+		hit, err := r.rule.check(ctx, flat, &details)
+		if err != nil {
+			r.errors.Add(1)
+			e.log.Error("failed to evaluate moderation rule", slog.String("rule", r.cfg.Name), slog.Any("error", err))
+			continue
+		}
+		if !hit {
+			continue
+		}
+		r.hits.Add(1)
+		decision.Rules = append(decision.Rules, r.cfg.Name)
+		if r.cfg.Action != ActionPass && details.DuplicateOf != nil {
+			decision.DuplicateOf = details.DuplicateOf
+		}
+		if severity[r.cfg.Action] > severity[decision.Action] {
+			decision.Action = r.cfg.Action
+			decision.Reason = r.cfg.Reason
+		}
+	}
+	return decision
+}
+
+// Apply evaluates a new or edited flat before it is saved and writes the
+// decision into it, so the flat is saved already declined or flagged and
+// linked to the original of its duplicates.
+func (e *Engine) Apply(ctx context.Context, flat *structures.Flat) Decision {
+	decision := e.Evaluate(ctx, *flat)
+	switch decision.Action {
+	case ActionDecline:
+		flat.Status = "declined"
+		flat.ModerationReason = decision.Reason
+		flat.Priority = false
+	case ActionFlag:
+		flat.ModerationReason = decision.Reason
+		flat.Priority = true
+	}
+	if decision.DuplicateOf != nil {
+		flat.DuplicateOf = decision.DuplicateOf
+	}
+	return decision
+}
+
+func (e *Engine) Stats() []RuleStats {
+	stats := make([]RuleStats, 0, len(e.rules))
+	for _, r := range e.rules {
+		stats = append(
+			stats, RuleStats{
+				Name:        r.cfg.Name,
+				Type:        r.cfg.Type,
+				Action:      r.cfg.Action,
+				Evaluations: r.evaluations.Load(),
+				Hits:        r.hits.Load(),
+				Errors:      r.errors.Load(),
+			},
+		)
+	}
+	return stats
+}
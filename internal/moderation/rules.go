@@ -0,0 +1,135 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+type Action string
+
+const (
+	ActionPass    Action = "pass"
+	ActionFlag    Action = "flag"
+	ActionDecline Action = "decline"
+)
+
+// severity orders the actions, the most severe hit decides the outcome.
+var severity = map[Action]int{ActionPass: 0, ActionFlag: 1, ActionDecline: 2}
+
+type RuleConfig struct {
+	Name   string `yaml:"name"`
+	Type   string `yaml:"type"`
+	Action Action `yaml:"action"`
+	Reason string `yaml:"reason"`
+
+	// banned_words
+	Words []string `yaml:"words"`
+	// price_per_room
+	Min int `yaml:"min"`
+	Max int `yaml:"max"`
+	// duplicate
+	PriceTolerance float64 `yaml:"price_tolerance"`
+}
+
+type Config struct {
+	Rules []RuleConfig `yaml:"rules"`
+}
+
+type rule interface {
+	// check reports whether the flat breaks the rule, the rule may add the
+	// details of the hit to the decision.
+	check(ctx context.Context, flat structures.Flat, details *Decision) (bool, error)
+}
+
+func newRule(cfg RuleConfig, finder duplicateFinder) (rule, error) {
+	switch cfg.Type {
+	case "banned_words":
+		words := make([]string, 0, len(cfg.Words))
+		for _, word := range cfg.Words {
+			words = append(words, normalizeText(word))
+		}
+		return bannedWords{words: words}, nil
+	case "price_per_room":
+		if cfg.Max > 0 && cfg.Min > cfg.Max {
+			return nil, fmt.Errorf("rule %s: min is greater than max", cfg.Name)
+		}
+		return pricePerRoom{min: cfg.Min, max: cfg.Max}, nil
+	case "duplicate":
+		if cfg.PriceTolerance < 0 || cfg.PriceTolerance >= 1 {
+			return nil, fmt.Errorf("rule %s: price_tolerance must be in [0, 1)", cfg.Name)
+		}
+		return duplicate{finder: finder, tolerance: cfg.PriceTolerance}, nil
+	default:
+		return nil, fmt.Errorf("rule %s: unknown type %q", cfg.Name, cfg.Type)
+	}
+}
+
+type bannedWords struct {
+	words []string
+}
+
+func (r bannedWords) check(_ context.Context, flat structures.Flat, _ *Decision) (bool, error) {
+	text := " " + normalizeText(flat.Description) + " "
+	for _, word := range r.words {
+		if strings.Contains(text, " "+word+" ") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// normalizeText lower-cases the text and replaces punctuation with spaces
+// so that words can be matched as whole words.
+func normalizeText(text string) string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	return strings.Join(strings.FieldsFunc(text, isSeparator), " ")
+}
+
+func isSeparator(r rune) bool {
+	return !(r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'а' && r <= 'я')
+}
+
+type pricePerRoom struct {
+	min int
+	max int
+}
+
+func (r pricePerRoom) check(_ context.Context, flat structures.Flat, _ *Decision) (bool, error) {
+	if flat.Rooms <= 0 {
+		return true, nil
+	}
+	price := flat.Price / flat.Rooms
+	return price < r.min || r.max > 0 && price > r.max, nil
+}
+
+// duplicate finds a seller posting the same flat again: same author, house,
+// rooms, number and area with a price within the tolerance. The flat is
+// linked to the first flat of the cluster so moderators review them
+// together.
+type duplicate struct {
+	finder    duplicateFinder
+	tolerance float64
+}
+
+func (r duplicate) check(ctx context.Context, flat structures.Flat, details *Decision) (bool, error) {
+	delta := int(math.Round(float64(flat.Price) * r.tolerance))
+	duplicates, err := r.finder.FindDuplicateFlats(ctx, flat, flat.Price-delta, flat.Price+delta)
+	if err != nil {
+		return false, err
+	}
+	if len(*duplicates) == 0 {
+		return false, nil
+	}
+
+	original := (*duplicates)[0]
+	originalId := original.Id
+	if original.DuplicateOf != nil {
+		originalId = *original.DuplicateOf
+	}
+	details.DuplicateOf = &originalId
+	return true, nil
+}
@@ -0,0 +1,227 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+type fakeFinder struct {
+	duplicates []structures.Flat
+	err        error
+	priceFrom  int
+	priceTo    int
+}
+
+func (f *fakeFinder) FindDuplicateFlats(
+	_ context.Context, _ structures.Flat, priceFrom, priceTo int,
+) (*[]structures.Flat, error) {
+	f.priceFrom, f.priceTo = priceFrom, priceTo
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &f.duplicates, nil
+}
+
+func mustRule(t *testing.T, cfg RuleConfig, finder duplicateFinder) rule {
+	t.Helper()
+	r, err := newRule(cfg, finder)
+	if err != nil {
+		t.Fatalf("newRule(%s): %v", cfg.Name, err)
+	}
+	return r
+}
+
+func TestBannedWords(t *testing.T) {
+	r := mustRule(t, RuleConfig{Name: "words", Type: "banned_words", Words: []string{"Казино", "предоплата на карту"}}, nil)
+	tests := []struct {
+		description string
+		want        bool
+	}{
+		{description: "Рядом КАЗИНО и парк", want: true},
+		{description: "рядом казино.", want: true},
+		{description: "Только предоплата   на карту!", want: true},
+		{description: "казиноход рядом", want: false},
+		{description: "предоплата наличными", want: false},
+		{description: "", want: false},
+	}
+	for _, tt := range tests {
+		hit, err := r.check(context.Background(), structures.Flat{Description: tt.description}, &Decision{})
+		if err != nil || hit != tt.want {
+			t.Errorf("check(%q) = %v, %v, want %v", tt.description, hit, err, tt.want)
+		}
+	}
+}
+
+func TestPricePerRoom(t *testing.T) {
+	r := mustRule(t, RuleConfig{Name: "price", Type: "price_per_room", Min: 100, Max: 1000}, nil)
+	tests := []struct {
+		price, rooms int
+		want         bool
+	}{
+		{price: 200, rooms: 2, want: false},
+		{price: 199, rooms: 2, want: true},
+		{price: 2000, rooms: 2, want: false},
+		{price: 2002, rooms: 2, want: true},
+		{price: 500, rooms: 0, want: true},
+	}
+	for _, tt := range tests {
+		hit, err := r.check(context.Background(), structures.Flat{Price: tt.price, Rooms: tt.rooms}, &Decision{})
+		if err != nil || hit != tt.want {
+			t.Errorf("check(price %d, rooms %d) = %v, %v, want %v", tt.price, tt.rooms, hit, err, tt.want)
+		}
+	}
+
+	unbounded := mustRule(t, RuleConfig{Name: "price", Type: "price_per_room", Min: 100}, nil)
+	if hit, _ := unbounded.check(context.Background(), structures.Flat{Price: 1 << 40, Rooms: 1}, &Decision{}); hit {
+		t.Error("zero max must not limit the price")
+	}
+
+	if _, err := newRule(RuleConfig{Name: "price", Type: "price_per_room", Min: 10, Max: 5}, nil); err == nil {
+		t.Error("min greater than max must be rejected")
+	}
+}
+
+func TestDuplicateTolerance(t *testing.T) {
+	finder := &fakeFinder{}
+	r := mustRule(t, RuleConfig{Name: "duplicate", Type: "duplicate", PriceTolerance: 0.05}, finder)
+
+	var details Decision
+	hit, err := r.check(context.Background(), structures.Flat{Price: 1000}, &details)
+	if err != nil || hit || details.DuplicateOf != nil {
+		t.Errorf("check without duplicate flats = %v, %v, duplicate of %v", hit, err, details.DuplicateOf)
+	}
+	if finder.priceFrom != 950 || finder.priceTo != 1050 {
+		t.Errorf("price range = [%d, %d], want [950, 1050]", finder.priceFrom, finder.priceTo)
+	}
+
+	finder.duplicates = []structures.Flat{{Id: 3}, {Id: 4}}
+	if hit, err = r.check(context.Background(), structures.Flat{Price: 1000}, &details); err != nil || !hit {
+		t.Errorf("check with a duplicate flat = %v, %v", hit, err)
+	}
+	if details.DuplicateOf == nil || *details.DuplicateOf != 3 {
+		t.Errorf("duplicate of %v, want the first duplicate flat 3", details.DuplicateOf)
+	}
+
+	// a duplicate of a duplicate joins the cluster of the original
+	original := 1
+	finder.duplicates = []structures.Flat{{Id: 3, DuplicateOf: &original}}
+	if hit, err = r.check(context.Background(), structures.Flat{Price: 1000}, &details); err != nil || !hit {
+		t.Errorf("check with a duplicate = %v, %v", hit, err)
+	}
+	if details.DuplicateOf == nil || *details.DuplicateOf != 1 {
+		t.Errorf("duplicate of %v, want the original flat 1", details.DuplicateOf)
+	}
+
+	for _, tolerance := range []float64{-0.1, 1} {
+		if _, err = newRule(RuleConfig{Name: "duplicate", Type: "duplicate", PriceTolerance: tolerance}, finder); err == nil {
+			t.Errorf("tolerance %v must be rejected", tolerance)
+		}
+	}
+}
+
+func testEngine(finder *fakeFinder, rules ...RuleConfig) *Engine {
+	engine := &Engine{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	for _, cfg := range rules {
+		r, err := newRule(cfg, finder)
+		if err != nil {
+			panic(err)
+		}
+		engine.rules = append(engine.rules, &loadedRule{cfg: cfg, rule: r})
+	}
+	return engine
+}
+
+func TestEvaluatePrecedence(t *testing.T) {
+	finder := &fakeFinder{duplicates: []structures.Flat{{Id: 3}}}
+	engine := testEngine(
+		finder,
+		RuleConfig{Name: "duplicate", Type: "duplicate", Action: ActionFlag, Reason: "duplicate"},
+		RuleConfig{Name: "words", Type: "banned_words", Action: ActionDecline, Reason: "words", Words: []string{"казино"}},
+		RuleConfig{Name: "price", Type: "price_per_room", Action: ActionPass, Reason: "price", Min: 100},
+	)
+
+	decision := engine.Evaluate(context.Background(), structures.Flat{Price: 10, Rooms: 1, Description: "казино"})
+	if decision.Action != ActionDecline || decision.Reason != "words" {
+		t.Errorf("decision = %+v, want decline by words", decision)
+	}
+	if strings.Join(decision.Rules, ",") != "duplicate,words,price" {
+		t.Errorf("rules = %v, want every broken rule", decision.Rules)
+	}
+
+	decision = engine.Evaluate(context.Background(), structures.Flat{Price: 10, Rooms: 1})
+	if decision.Action != ActionFlag || decision.Reason != "duplicate" {
+		t.Errorf("decision = %+v, want flag by duplicate", decision)
+	}
+
+	finder.duplicates = nil
+	decision = engine.Evaluate(context.Background(), structures.Flat{Price: 10, Rooms: 1})
+	if decision.Action != ActionPass || decision.Reason != "" {
+		t.Errorf("decision = %+v, want pass without a reason", decision)
+	}
+
+	finder.err = errors.New("database is down")
+	decision = engine.Evaluate(context.Background(), structures.Flat{Price: 1000, Rooms: 1})
+	if decision.Action != ActionPass {
+		t.Errorf("decision = %+v, a failing rule must be skipped", decision)
+	}
+	stats := engine.Stats()
+	if stats[0].Errors != 1 || stats[0].Evaluations != 4 || stats[0].Hits != 2 {
+		t.Errorf("duplicate stats = %+v", stats[0])
+	}
+}
+
+func TestApply(t *testing.T) {
+	engine := testEngine(
+		&fakeFinder{},
+		RuleConfig{Name: "words", Type: "banned_words", Action: ActionDecline, Reason: "words", Words: []string{"казино"}},
+		RuleConfig{Name: "price", Type: "price_per_room", Action: ActionFlag, Reason: "price", Min: 100},
+	)
+
+	flat := structures.Flat{Price: 1000, Rooms: 1, Description: "казино", Status: "created"}
+	engine.Apply(context.Background(), &flat)
+	if flat.Status != "declined" || flat.ModerationReason != "words" || flat.Priority {
+		t.Errorf("declined flat = %+v", flat)
+	}
+
+	flat = structures.Flat{Price: 10, Rooms: 1, Status: "created"}
+	engine.Apply(context.Background(), &flat)
+	if flat.Status != "created" || flat.ModerationReason != "price" || !flat.Priority {
+		t.Errorf("flagged flat = %+v", flat)
+	}
+
+	flat = structures.Flat{Price: 1000, Rooms: 1, Status: "created"}
+	engine.Apply(context.Background(), &flat)
+	if flat.Status != "created" || flat.ModerationReason != "" || flat.Priority {
+		t.Errorf("passed flat = %+v", flat)
+	}
+}
+
+func TestApplyLinksDuplicates(t *testing.T) {
+	finder := &fakeFinder{duplicates: []structures.Flat{{Id: 3}}}
+	engine := testEngine(
+		finder,
+		RuleConfig{Name: "duplicate", Type: "duplicate", Action: ActionFlag, Reason: "duplicate", PriceTolerance: 0.05},
+	)
+
+	flat := structures.Flat{Price: 1000, Rooms: 1, Status: "created"}
+	decision := engine.Apply(context.Background(), &flat)
+	if decision.DuplicateOf == nil || flat.DuplicateOf == nil || *flat.DuplicateOf != 3 {
+		t.Errorf("flat = %+v, decision = %+v, want a duplicate of 3", flat, decision)
+	}
+	if !flat.Priority || flat.ModerationReason != "duplicate" {
+		t.Errorf("flat = %+v, want flagged", flat)
+	}
+
+	// a rule which only counts hits does not link the flat
+	engine.rules[0].cfg.Action = ActionPass
+	flat = structures.Flat{Price: 1000, Rooms: 1, Status: "created"}
+	if decision = engine.Apply(context.Background(), &flat); decision.DuplicateOf != nil || flat.DuplicateOf != nil {
+		t.Errorf("flat = %+v, decision = %+v, want no link", flat, decision)
+	}
+}
@@ -0,0 +1,45 @@
+package notifications
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+type metrics struct {
+	enqueued   atomic.Int64
+	duplicates atomic.Int64
+	sent       atomic.Int64
+	failed     atomic.Int64
+	retried    atomic.Int64
+	dead       atomic.Int64
+}
+
+// Metrics are the delivery outcomes of this replica since the start and the
+// jobs of all replicas by status.
+type Metrics struct {
+	Enqueued   int64                       `json:"enqueued"`
+	Duplicates int64                       `json:"duplicates"`
+	Sent       int64                       `json:"sent"`
+	Failed     int64                       `json:"failed"`
+	Retried    int64                       `json:"retried"`
+	Dead       int64                       `json:"dead"`
+	Jobs       *[]structures.EmailJobCount `json:"jobs"`
+}
+
+func (q *Queue) Metrics(ctx context.Context) (*Metrics, error) {
+	jobs, err := q.store.CountEmailJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Metrics{
+		Enqueued:   q.metrics.enqueued.Load(),
+		Duplicates: q.metrics.duplicates.Load(),
+		Sent:       q.metrics.sent.Load(),
+		Failed:     q.metrics.failed.Load(),
+		Retried:    q.metrics.retried.Load(),
+		Dead:       q.metrics.dead.Load(),
+		Jobs:       jobs,
+	}, nil
+}
@@ -0,0 +1,207 @@
+package notifications
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+// maxErrorLength bounds the error text kept with the job.
+const maxErrorLength = 500
+
+// Sender delivers a message to the recipient, pkg/sender implements it.
+type Sender interface {
+	SendEmail(ctx context.Context, recipient string, message string) error
+}
+
+type store interface {
+	EnqueueEmailJob(ctx context.Context, key, recipient, message string) (bool, error)
+	ClaimEmailJobs(ctx context.Context, limit int, lease time.Duration) (*[]structures.EmailJob, error)
+	UpdateEmailJob(ctx context.Context, job structures.EmailJob) (bool, error)
+	DeleteSentEmailJobs(ctx context.Context, before time.Time) (int64, error)
+	CountEmailJobs(ctx context.Context) (*[]structures.EmailJobCount, error)
+}
+
+type Options struct {
+	Workers      int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	SendTimeout  time.Duration
+	PollInterval time.Duration
+	// Retention is how long the sent jobs are kept, their idempotency keys
+	// stop repeated messages only while they are kept
+	Retention time.Duration
+}
+
+// cleanupInterval is how often the sent jobs past the retention are removed.
+const cleanupInterval = time.Hour
+
+// Queue is a persistent email queue in Postgres. A bounded pool of workers
+// sends the due jobs, a failed job is retried with an exponential backoff
+// with jitter until it runs out of attempts and becomes dead. Every job has
+// an idempotency key, enqueueing the same key again does nothing while the
+// job is kept, so a message is not sent twice.
+type Queue struct {
+	log     *slog.Logger
+	store   store
+	sender  Sender
+	opts    Options
+	metrics metrics
+	now     func() time.Time
+	jitter  func() float64
+}
+
+func New(log *slog.Logger, store store, sender Sender, opts Options) *Queue {
+	return &Queue{
+		log:    log,
+		store:  store,
+		sender: sender,
+		opts:   opts,
+		now:    time.Now,
+		jitter: rand.Float64,
+	}
+}
+
+// Enqueue stores the message for sending, a repeated key is ignored.
+func (q *Queue) Enqueue(ctx context.Context, key, recipient, message string) error {
+	added, err := q.store.EnqueueEmailJob(ctx, key, recipient, message)
+	if err != nil {
+		return err
+	}
+	if added {
+		q.metrics.enqueued.Add(1)
+	} else {
+		q.metrics.duplicates.Add(1)
+	}
+	return nil
+}
+
+// Run sends the jobs until ctx is done and the workers have finished the
+// jobs in progress.
+func (q *Queue) Run(ctx context.Context) {
+	jobs := make(chan structures.EmailJob)
+	var wg sync.WaitGroup
+	for i := 0; i < q.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				q.process(ctx, job)
+			}
+		}()
+	}
+
+	q.poll(ctx, jobs)
+	close(jobs)
+	wg.Wait()
+}
+
+func (q *Queue) poll(ctx context.Context, jobs chan<- structures.EmailJob) {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(cleanupInterval)
+	defer cleanup.Stop()
+
+	// a claimed job waits at most for one send of a busy worker
+	lease := 2*q.opts.SendTimeout + q.opts.PollInterval
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanup.C:
+			q.cleanup(ctx)
+			continue
+		case <-ticker.C:
+		}
+
+		claimed, err := q.store.ClaimEmailJobs(ctx, q.opts.Workers, lease)
+		if err != nil {
+			q.log.Error("failed to claim email jobs", slog.Any("error", err))
+			continue
+		}
+		for _, job := range *claimed {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				// the lease expires and another replica picks the job up
+				return
+			}
+		}
+	}
+}
+
+// process sends the job once and records the outcome, the outcome is
+// recorded even if ctx is done meanwhile.
+func (q *Queue) process(ctx context.Context, job structures.EmailJob) {
+	sendCtx, cancel := context.WithTimeout(ctx, q.opts.SendTimeout)
+	err := q.sender.SendEmail(sendCtx, job.Recipient, job.Message)
+	cancel()
+
+	job.Attempts++
+	switch {
+	case err == nil:
+		job.Status = structures.JobSent
+		job.LastError = ""
+		q.metrics.sent.Add(1)
+	case job.Attempts >= q.opts.MaxAttempts:
+		job.Status = structures.JobDead
+		job.LastError = truncate(err.Error())
+		q.metrics.failed.Add(1)
+		q.metrics.dead.Add(1)
+		q.log.Warn("email job is dead", slog.Int("job_id", job.Id), slog.Any("error", err))
+	default:
+		job.Status = structures.JobPending
+		job.LastError = truncate(err.Error())
+		job.NextAttemptAt = q.now().Add(q.backoff(job.Attempts))
+		q.metrics.failed.Add(1)
+		q.metrics.retried.Add(1)
+	}
+
+	updated, err := q.store.UpdateEmailJob(context.WithoutCancel(ctx), job)
+	if err != nil {
+		q.log.Error("failed to update email job", slog.Int("job_id", job.Id), slog.Any("error", err))
+		return
+	}
+	if !updated {
+		q.log.Warn("email job lease expired, the outcome is dropped", slog.Int("job_id", job.Id))
+	}
+}
+
+// cleanup removes the jobs sent before the retention period.
+func (q *Queue) cleanup(ctx context.Context) {
+	deleted, err := q.store.DeleteSentEmailJobs(ctx, q.now().Add(-q.opts.Retention))
+	if err != nil {
+		q.log.Error("failed to delete sent email jobs", slog.Any("error", err))
+		return
+	}
+	if deleted > 0 {
+		q.log.Info("deleted sent email jobs", slog.Int64("count", deleted))
+	}
+}
+
+// backoff returns the delay after the attempt: the base delay doubled for
+// every failed attempt and capped, the second half of it is random so the
+// retries of a burst of failures spread out.
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.opts.BaseBackoff
+	for i := 1; i < attempts && delay < q.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > q.opts.MaxBackoff {
+		delay = q.opts.MaxBackoff
+	}
+	half := delay / 2
+	return half + time.Duration(q.jitter()*float64(delay-half))
+}
+
+func truncate(str string) string {
+	if len(str) > maxErrorLength {
+		return str[:maxErrorLength]
+	}
+	return str
+}
@@ -0,0 +1,264 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/google/uuid"
+)
+
+// fakeStore keeps the jobs by key and checks the lease token on update the
+// way the storage does.
+type fakeStore struct {
+	mu      sync.Mutex
+	jobs    map[string]*structures.EmailJob
+	nextId  int
+	deleted []time.Time
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{jobs: make(map[string]*structures.EmailJob)}
+}
+
+func (s *fakeStore) EnqueueEmailJob(_ context.Context, key, recipient, message string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[key]; ok {
+		return false, nil
+	}
+	s.nextId++
+	s.jobs[key] = &structures.EmailJob{
+		Id: s.nextId, IdempotencyKey: key, Recipient: recipient, Message: message, Status: structures.JobPending,
+	}
+	return true, nil
+}
+
+func (s *fakeStore) ClaimEmailJobs(context.Context, int, time.Duration) (*[]structures.EmailJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := uuid.New()
+	claimed := make([]structures.EmailJob, 0)
+	for _, job := range s.jobs {
+		if job.Status == structures.JobPending {
+			job.LeaseToken = token
+			claimed = append(claimed, *job)
+		}
+	}
+	return &claimed, nil
+}
+
+func (s *fakeStore) UpdateEmailJob(_ context.Context, job structures.EmailJob) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.jobs[job.IdempotencyKey]
+	if !ok || stored.LeaseToken != job.LeaseToken {
+		return false, nil
+	}
+	job.LeaseToken = uuid.Nil
+	*stored = job
+	return true, nil
+}
+
+func (s *fakeStore) DeleteSentEmailJobs(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = append(s.deleted, before)
+	return 0, nil
+}
+
+func (s *fakeStore) CountEmailJobs(context.Context) (*[]structures.EmailJobCount, error) {
+	return &[]structures.EmailJobCount{}, nil
+}
+
+func (s *fakeStore) job(key string) structures.EmailJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.jobs[key]
+}
+
+type fakeSender struct {
+	err   error
+	sends int
+}
+
+func (s *fakeSender) SendEmail(context.Context, string, string) error {
+	s.sends++
+	return s.err
+}
+
+var testNow = time.Date(2024, 9, 8, 12, 0, 0, 0, time.UTC)
+
+func newTestQueue(store store, sender Sender, jitter float64) *Queue {
+	q := New(
+		slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		store,
+		sender,
+		Options{
+			Workers:      1,
+			MaxAttempts:  3,
+			BaseBackoff:  time.Second,
+			MaxBackoff:   10 * time.Second,
+			SendTimeout:  time.Second,
+			PollInterval: time.Second,
+			Retention:    24 * time.Hour,
+		},
+	)
+	q.now = func() time.Time { return testNow }
+	q.jitter = func() float64 { return jitter }
+	return q
+}
+
+// claim returns the only claimed job.
+func claim(t *testing.T, store *fakeStore) structures.EmailJob {
+	t.Helper()
+	claimed, err := store.ClaimEmailJobs(context.Background(), 1, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimEmailJobs: %v", err)
+	}
+	if len(*claimed) != 1 {
+		t.Fatalf("claimed %d jobs, want 1", len(*claimed))
+	}
+	return (*claimed)[0]
+}
+
+func TestBackoff(t *testing.T) {
+	q := newTestQueue(newFakeStore(), &fakeSender{}, 1)
+
+	// the whole delay with the largest jitter
+	want := []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second,
+	}
+	for i, delay := range want {
+		if got := q.backoff(i + 1); got != delay {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, delay)
+		}
+	}
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	tests := []struct {
+		jitter float64
+		want   time.Duration
+	}{
+		{0, 2 * time.Second},
+		{0.5, 3 * time.Second},
+		{0.999, 3998 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		q := newTestQueue(newFakeStore(), &fakeSender{}, tt.jitter)
+		got := q.backoff(3)
+		if got != tt.want {
+			t.Errorf("backoff(3) with jitter %v = %s, want %s", tt.jitter, got, tt.want)
+		}
+		if got < 2*time.Second || got > 4*time.Second {
+			t.Errorf("backoff(3) with jitter %v = %s, out of [2s, 4s]", tt.jitter, got)
+		}
+	}
+}
+
+func TestProcessRetriesThenDies(t *testing.T) {
+	store := newFakeStore()
+	sender := &fakeSender{err: errors.New("smtp is down")}
+	q := newTestQueue(store, sender, 0)
+	ctx := context.Background()
+	if err := q.Enqueue(ctx, "key", "user@example.com", "hello"); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	for attempt := 1; attempt < q.opts.MaxAttempts; attempt++ {
+		q.process(ctx, claim(t, store))
+		job := store.job("key")
+		if job.Status != structures.JobPending || job.Attempts != attempt {
+			t.Fatalf("after attempt %d job is %s with %d attempts", attempt, job.Status, job.Attempts)
+		}
+		if want := testNow.Add(q.backoff(attempt)); !job.NextAttemptAt.Equal(want) {
+			t.Errorf("after attempt %d next attempt at %s, want %s", attempt, job.NextAttemptAt, want)
+		}
+		if job.LastError != "smtp is down" {
+			t.Errorf("last error = %q", job.LastError)
+		}
+	}
+
+	q.process(ctx, claim(t, store))
+	job := store.job("key")
+	if job.Status != structures.JobDead || job.Attempts != q.opts.MaxAttempts {
+		t.Errorf("job is %s with %d attempts, want dead with %d", job.Status, job.Attempts, q.opts.MaxAttempts)
+	}
+	if q.metrics.dead.Load() != 1 || q.metrics.retried.Load() != int64(q.opts.MaxAttempts-1) {
+		t.Errorf("dead = %d, retried = %d", q.metrics.dead.Load(), q.metrics.retried.Load())
+	}
+}
+
+func TestProcessSends(t *testing.T) {
+	store := newFakeStore()
+	q := newTestQueue(store, &fakeSender{}, 0)
+	ctx := context.Background()
+	if err := q.Enqueue(ctx, "key", "user@example.com", "hello"); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	q.process(ctx, claim(t, store))
+
+	if job := store.job("key"); job.Status != structures.JobSent || job.Attempts != 1 {
+		t.Errorf("job is %s with %d attempts, want sent with 1", job.Status, job.Attempts)
+	}
+}
+
+func TestProcessDropsOutcomeOfExpiredLease(t *testing.T) {
+	store := newFakeStore()
+	q := newTestQueue(store, &fakeSender{err: errors.New("timeout")}, 0)
+	ctx := context.Background()
+	if err := q.Enqueue(ctx, "key", "user@example.com", "hello"); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	stale := claim(t, store)
+	// the lease expires and the job is claimed and sent again
+	fresh := claim(t, store)
+	q.sender = &fakeSender{}
+	q.process(ctx, fresh)
+	q.sender = &fakeSender{err: errors.New("timeout")}
+	q.process(ctx, stale)
+
+	if job := store.job("key"); job.Status != structures.JobSent {
+		t.Errorf("job is %s, want the sent outcome kept", job.Status)
+	}
+}
+
+func TestEnqueueDuplicate(t *testing.T) {
+	store := newFakeStore()
+	sender := &fakeSender{}
+	q := newTestQueue(store, sender, 0)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := q.Enqueue(ctx, "key", "user@example.com", "hello"); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	q.process(ctx, claim(t, store))
+
+	if len(store.jobs) != 1 || sender.sends != 1 {
+		t.Errorf("jobs = %d, sends = %d, want 1 and 1", len(store.jobs), sender.sends)
+	}
+	if q.metrics.enqueued.Load() != 1 || q.metrics.duplicates.Load() != 2 {
+		t.Errorf("enqueued = %d, duplicates = %d, want 1 and 2", q.metrics.enqueued.Load(), q.metrics.duplicates.Load())
+	}
+}
+
+func TestCleanupKeepsRetention(t *testing.T) {
+	store := newFakeStore()
+	q := newTestQueue(store, &fakeSender{}, 0)
+
+	q.cleanup(context.Background())
+
+	if len(store.deleted) != 1 || !store.deleted[0].Equal(testNow.Add(-24*time.Hour)) {
+		t.Errorf("deleted sent before %v, want %s", store.deleted, testNow.Add(-24*time.Hour))
+	}
+}
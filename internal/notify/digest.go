@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/templates"
+	"github.com/google/uuid"
+)
+
+type digestStore interface {
+	dispatchStore
+	TakePendingNotifications(ctx context.Context, deliveries []string) (*[]structures.PendingNotification, error)
+}
+
+// Digest aggregates the pending notifications of a user into a single
+// message every hour or every day depending on the user preference. The
+// digests are sent at the start of an hour by the wall clock, so a restart
+// does not move them.
+type Digest struct {
+	log        *slog.Logger
+	store      digestStore
+	dispatcher *Dispatcher
+	// dailyHour is the UTC hour the daily digest is sent at
+	dailyHour int
+}
+
+func NewDigest(log *slog.Logger, store digestStore, dispatcher *Dispatcher, dailyHour int) *Digest {
+	return &Digest{log: log, store: store, dispatcher: dispatcher, dailyHour: dailyHour}
+}
+
+// Run sends the digests until ctx is done. The hourly run also sends the
+// notifications of users who switched from a digest to instant delivery.
+func (d *Digest) Run(ctx context.Context) {
+	for {
+		next := time.Now().UTC().Truncate(time.Hour).Add(time.Hour)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		for _, deliveries := range d.due(next) {
+			d.send(ctx, deliveries...)
+		}
+	}
+}
+
+// due returns the deliveries of the digests sent at the start of the hour.
+func (d *Digest) due(hour time.Time) [][]string {
+	due := [][]string{{structures.DeliveryHourly, structures.DeliveryInstant}}
+	if hour.UTC().Hour() == d.dailyHour {
+		due = append(due, []string{structures.DeliveryDaily})
+	}
+	return due
+}
+
+func (d *Digest) send(ctx context.Context, deliveries ...string) {
+	const op = "notify.digest.send"
+	log := d.log.With(slog.String("op", op), slog.Any("deliveries", deliveries))
+
+	pending, err := d.store.TakePendingNotifications(ctx, deliveries)
+	if err != nil {
+		log.Error("failed to take pending notifications", slog.Any("error", err))
+		return
+	}
+
+	users := make(map[uuid.UUID][]structures.PendingNotification)
+	for _, notification := range *pending {
+		users[notification.UserId] = append(users[notification.UserId], notification)
+	}
+
+	for userId, notifications := range users {
+		recipient := structures.Recipient{
+			UserId: userId,
+			Email:  notifications[0].Email,
+			Locale: notifications[0].Locale,
+		}
+		key := fmt.Sprintf("digest:%d", lastId(notifications))
+		err = d.dispatcher.send(ctx, recipient, key, templates.Digest, digestData(notifications), d.dispatcher.tokens.UnsubscribeURL(userId))
+		if err == nil {
+			continue
+		}
+		// the notifications are taken from the store, put them back for the
+		// next digest
+		for _, notification := range notifications {
+			if err = d.store.SavePendingNotification(ctx, userId, notification.Message); err != nil {
+				log.Error("failed to restore pending notification", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+func digestData(notifications []structures.PendingNotification) templates.DigestData {
+	items := make([]templates.DigestItem, 0, len(notifications))
+	for _, notification := range notifications {
+		items = append(items, templates.DigestItem{At: notification.CreatedAt, Text: notification.Message})
+	}
+	return templates.DigestData{Items: items}
+}
+
+// lastId identifies the digest, the pending notifications are taken once.
+func lastId(notifications []structures.PendingNotification) int {
+	id := 0
+	for _, notification := range notifications {
+		id = max(id, notification.Id)
+	}
+	return id
+}
@@ -0,0 +1,26 @@
+package notify
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDigestDue(t *testing.T) {
+	digest := NewDigest(discardLog, nil, nil, 9)
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{at: time.Date(2024, 9, 8, 8, 0, 0, 0, time.UTC), want: "[[hourly instant]]"},
+		{at: time.Date(2024, 9, 8, 9, 0, 0, 0, time.UTC), want: "[[hourly instant] [daily]]"},
+		// the daily hour is in UTC whatever the local zone is
+		{at: time.Date(2024, 9, 8, 12, 0, 0, 0, time.FixedZone("MSK", 3*3600)), want: "[[hourly instant] [daily]]"},
+		{at: time.Date(2024, 9, 8, 10, 0, 0, 0, time.UTC), want: "[[hourly instant]]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(digest.due(tt.at)); got != tt.want {
+			t.Errorf("due(%v) = %s, want %s", tt.at, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/templates"
+	"github.com/google/uuid"
+)
+
+type favoriteSource interface {
+	ClaimPriceChangeRecipients(ctx context.Context, flatId, price int) (*[]structures.PriceChangeRecipient, error)
+	GetRecipient(ctx context.Context, userId uuid.UUID) (*structures.Recipient, error)
+}
+
+// Notifier tells users about changes of the flats they saved and authors
+// about the moderation of their flats. The sender is slow, so the
+// notifications are sent in the background bound to the app context.
+type Notifier struct {
+	ctx        context.Context
+	log        *slog.Logger
+	source     favoriteSource
+	dispatcher *Dispatcher
+}
+
+func New(ctx context.Context, log *slog.Logger, source favoriteSource, dispatcher *Dispatcher) *Notifier {
+	return &Notifier{ctx: ctx, log: log, source: source, dispatcher: dispatcher}
+}
+
+// Handle notifies the author about the moderation outcome and the users who
+// saved the flat about a new price. An edited flat is moderated again, so
+// the price is sent once the flat is approved with it.
+func (n *Notifier) Handle(event events.Event) {
+	switch event.Type {
+	case events.FlatApproved:
+		go n.moderated(event)
+		go n.priceChanged(event)
+	case events.FlatDeclined:
+		go n.moderated(event)
+	}
+}
+
+func (n *Notifier) priceChanged(event events.Event) {
+	const op = "notify.priceChanged"
+	flat := event.Flat
+	log := n.log.With(slog.String("op", op), slog.Int("flat_id", flat.Id))
+
+	recipients, err := n.source.ClaimPriceChangeRecipients(n.ctx, flat.Id, flat.Price)
+	if err != nil {
+		log.Error("failed to get favorite recipients", slog.Any("error", err))
+		return
+	}
+
+	key := fmt.Sprintf("price:%d:%d", flat.Id, event.CreatedAt.UnixNano())
+	for _, recipient := range *recipients {
+		data := templates.PriceChangedData{FlatId: flat.Id, OldPrice: recipient.OldPrice, NewPrice: flat.Price}
+		_ = n.dispatcher.Deliver(n.ctx, recipient.Recipient, key, templates.PriceChanged, data)
+	}
+}
+
+// moderated tells the author whether the flat passed the moderation, the
+// email does not depend on the delivery preference.
+func (n *Notifier) moderated(event events.Event) {
+	const op = "notify.moderated"
+	flat := event.Flat
+	log := n.log.With(slog.String("op", op), slog.Int("flat_id", flat.Id))
+
+	// flats created before authors were stored have nobody to notify
+	if flat.AuthorId == nil {
+		return
+	}
+	recipient, err := n.source.GetRecipient(n.ctx, *flat.AuthorId)
+	if err != nil {
+		log.Error("failed to get flat author", slog.Any("error", err))
+		return
+	}
+
+	key := fmt.Sprintf("moderation:%d:%d", flat.Id, event.CreatedAt.UnixNano())
+	data := templates.ModerationOutcomeData{
+		FlatId:   flat.Id,
+		Approved: event.Type == events.FlatApproved,
+		Reason:   flat.ModerationReason,
+	}
+	_ = n.dispatcher.Send(n.ctx, *recipient, key, templates.ModerationOutcome, data)
+}
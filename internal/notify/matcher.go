@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/templates"
+	"github.com/google/uuid"
+)
+
+const (
+	// searchAlertsBatch bounds the approved flats matched at once, the rest
+	// wait for the next interval.
+	searchAlertsBatch = 1000
+	// searchAlertsLease is how long a batch is reserved for the replica
+	// sending it.
+	searchAlertsLease = 5 * time.Minute
+)
+
+type matchSource interface {
+	FindSearchMatches(ctx context.Context, flatIds []int) (*[]structures.SearchMatch, error)
+	QueueSearchAlert(ctx context.Context, flatId int) error
+	ClaimSearchAlerts(ctx context.Context, limit int, lease time.Duration) (*[]int, error)
+	DeleteSearchAlerts(ctx context.Context, flatIds []int) error
+}
+
+// Matcher alerts users about approved flats matching their saved searches.
+// The flats are collected for an interval so a user gets a single email with
+// all the new flats instead of one email per flat. The approved flats wait
+// in Postgres, so a restart does not lose them.
+type Matcher struct {
+	log        *slog.Logger
+	source     matchSource
+	dispatcher *Dispatcher
+	interval   time.Duration
+}
+
+func NewMatcher(log *slog.Logger, source matchSource, dispatcher *Dispatcher, interval time.Duration) *Matcher {
+	return &Matcher{
+		log:        log,
+		source:     source,
+		dispatcher: dispatcher,
+		interval:   interval,
+	}
+}
+
+// Handle queues an approved flat for the next batch.
+func (m *Matcher) Handle(ctx context.Context, event events.Event) {
+	if event.Type != events.FlatApproved {
+		return
+	}
+	if err := m.source.QueueSearchAlert(ctx, event.Flat.Id); err != nil {
+		m.log.Error("failed to queue search alert", slog.Int("flat_id", event.Flat.Id), slog.Any("error", err))
+	}
+}
+
+// Run sends the alerts about the queued flats every interval until ctx is
+// done.
+func (m *Matcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.flush(ctx)
+		}
+	}
+}
+
+// flush sends the alerts about a batch of the queued flats. A flat stays
+// queued unless all its alerts are delivered and is claimed again after the
+// lease, the emails already sent are not repeated because the alert key
+// depends only on the flats.
+func (m *Matcher) flush(ctx context.Context) {
+	const op = "notify.matcher.flush"
+	log := m.log.With(slog.String("op", op))
+
+	flatIds, err := m.source.ClaimSearchAlerts(ctx, searchAlertsBatch, searchAlertsLease)
+	if err != nil {
+		log.Error("failed to claim search alerts", slog.Any("error", err))
+		return
+	}
+	if len(*flatIds) == 0 {
+		return
+	}
+
+	matches, err := m.source.FindSearchMatches(ctx, *flatIds)
+	if err != nil {
+		log.Error("failed to find search matches", slog.Any("error", err))
+		return
+	}
+
+	failed := make(map[int]bool)
+	for _, flats := range groupMatches(*matches) {
+		err = m.dispatcher.Deliver(ctx, flats[0].Recipient, searchAlertKey(flats), templates.Subscription, subscriptionData(flats))
+		if err == nil {
+			continue
+		}
+		for _, flat := range flats {
+			failed[flat.FlatId] = true
+		}
+	}
+
+	sent := make([]int, 0, len(*flatIds))
+	for _, id := range *flatIds {
+		if !failed[id] {
+			sent = append(sent, id)
+		}
+	}
+	if len(failed) > 0 {
+		log.Warn("search alerts are left for a retry", slog.Int("flats", len(failed)))
+	}
+	if len(sent) == 0 {
+		return
+	}
+	if err = m.source.DeleteSearchAlerts(ctx, sent); err != nil {
+		log.Error("failed to delete sent search alerts", slog.Any("error", err))
+	}
+}
+
+// groupMatches returns the matched flats per user, a flat matching several
+// searches of the user is listed once.
+func groupMatches(matches []structures.SearchMatch) map[uuid.UUID][]structures.SearchMatch {
+	grouped := make(map[uuid.UUID][]structures.SearchMatch)
+	seen := make(map[uuid.UUID]map[int]bool)
+	for _, match := range matches {
+		if seen[match.UserId] == nil {
+			seen[match.UserId] = make(map[int]bool)
+		}
+		if seen[match.UserId][match.FlatId] {
+			continue
+		}
+		seen[match.UserId][match.FlatId] = true
+		grouped[match.UserId] = append(grouped[match.UserId], match)
+	}
+	return grouped
+}
+
+// searchAlertKey identifies the alert by the hash of its flats, a flat
+// approved again later makes a new alert only together with other flats.
+func searchAlertKey(flats []structures.SearchMatch) string {
+	h := sha256.New()
+	for _, flat := range flats {
+		fmt.Fprintf(h, "%d,", flat.FlatId)
+	}
+	return "search:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func subscriptionData(flats []structures.SearchMatch) templates.SubscriptionData {
+	data := templates.SubscriptionData{Flats: make([]templates.SubscriptionFlat, 0, len(flats))}
+	for _, flat := range flats {
+		data.Flats = append(data.Flats, templates.SubscriptionFlat{
+			FlatId:  flat.FlatId,
+			Address: flat.Address,
+			Rooms:   flat.Rooms,
+			Price:   flat.Price,
+		})
+	}
+	return data
+}
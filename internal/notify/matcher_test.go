@@ -0,0 +1,229 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/templates"
+	"github.com/google/uuid"
+)
+
+var discardLog = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func match(userId uuid.UUID, searchId, flatId int) structures.SearchMatch {
+	return structures.SearchMatch{
+		Recipient: structures.Recipient{UserId: userId, Email: userId.String() + "@example.com"},
+		SearchId:  searchId,
+		FlatId:    flatId,
+	}
+}
+
+func flatIds(matches []structures.SearchMatch) []int {
+	ids := make([]int, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.FlatId)
+	}
+	return ids
+}
+
+func TestGroupMatches(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	grouped := groupMatches(
+		[]structures.SearchMatch{
+			match(alice, 1, 10),
+			match(alice, 2, 10),
+			match(alice, 1, 11),
+			match(bob, 3, 10),
+			match(bob, 3, 12),
+		},
+	)
+
+	if len(grouped) != 2 {
+		t.Fatalf("groups = %d, want 2", len(grouped))
+	}
+	if got := flatIds(grouped[alice]); len(got) != 2 || got[0] != 10 || got[1] != 11 {
+		t.Errorf("alice flats = %v, want [10 11] with the flat of two searches once", got)
+	}
+	if got := flatIds(grouped[bob]); len(got) != 2 || got[0] != 10 || got[1] != 12 {
+		t.Errorf("bob flats = %v, want [10 12]", got)
+	}
+}
+
+func TestSearchAlertKey(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	key := searchAlertKey([]structures.SearchMatch{match(alice, 1, 10), match(alice, 1, 11)})
+
+	if !strings.HasPrefix(key, "search:") {
+		t.Errorf("key = %q, want the search: prefix", key)
+	}
+	// the key depends only on the flats, the recipient is added by the dispatcher
+	if same := searchAlertKey([]structures.SearchMatch{match(bob, 2, 10), match(bob, 2, 11)}); same != key {
+		t.Errorf("key of the same flats = %q, want %q", same, key)
+	}
+	if other := searchAlertKey([]structures.SearchMatch{match(alice, 1, 10)}); other == key {
+		t.Error("a subset of the flats must make another key")
+	}
+	// "1,12," and "11,2," must not collide
+	a := searchAlertKey([]structures.SearchMatch{match(alice, 1, 1), match(alice, 1, 12)})
+	b := searchAlertKey([]structures.SearchMatch{match(alice, 1, 11), match(alice, 1, 2)})
+	if a == b {
+		t.Error("keys of different flats collide")
+	}
+}
+
+type fakeMatchSource struct {
+	mu       sync.Mutex
+	queue    map[int]time.Time
+	matches  []structures.SearchMatch
+	matchErr error
+}
+
+func (s *fakeMatchSource) FindSearchMatches(_ context.Context, flatIds []int) (*[]structures.SearchMatch, error) {
+	if s.matchErr != nil {
+		return nil, s.matchErr
+	}
+	result := make([]structures.SearchMatch, 0)
+	for _, m := range s.matches {
+		for _, id := range flatIds {
+			if m.FlatId == id {
+				result = append(result, m)
+			}
+		}
+	}
+	return &result, nil
+}
+
+func (s *fakeMatchSource) QueueSearchAlert(_ context.Context, flatId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.queue[flatId]; !ok {
+		s.queue[flatId] = time.Time{}
+	}
+	return nil
+}
+
+func (s *fakeMatchSource) ClaimSearchAlerts(_ context.Context, limit int, lease time.Duration) (*[]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]int, 0)
+	for id, claimedUntil := range s.queue {
+		if len(ids) < limit && !claimedUntil.After(time.Now()) {
+			s.queue[id] = time.Now().Add(lease)
+			ids = append(ids, id)
+		}
+	}
+	return &ids, nil
+}
+
+func (s *fakeMatchSource) DeleteSearchAlerts(_ context.Context, flatIds []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range flatIds {
+		delete(s.queue, id)
+	}
+	return nil
+}
+
+// fakeOutbox fails the emails to the recipients in fail.
+type fakeOutbox struct {
+	keys []string
+	fail map[string]bool
+}
+
+func (o *fakeOutbox) Enqueue(_ context.Context, key, recipient, _ string) error {
+	if o.fail[recipient] {
+		return errors.New("outbox is down")
+	}
+	o.keys = append(o.keys, key)
+	return nil
+}
+
+func testDispatcher(t *testing.T, outbox Outbox) *Dispatcher {
+	t.Helper()
+	renderer, err := templates.New()
+	if err != nil {
+		t.Fatalf("templates.New: %v", err)
+	}
+	return NewDispatcher(discardLog, nil, outbox, NewTokens("secret", "http://localhost"), renderer)
+}
+
+func TestMatcherKeepsQueueUntilSent(t *testing.T) {
+	alice := uuid.New()
+	source := &fakeMatchSource{
+		queue:    map[int]time.Time{},
+		matches:  []structures.SearchMatch{match(alice, 1, 10)},
+		matchErr: errors.New("database is down"),
+	}
+	outbox := &fakeOutbox{}
+	matcher := NewMatcher(discardLog, source, testDispatcher(t, outbox), time.Minute)
+	ctx := context.Background()
+
+	matcher.Handle(ctx, events.Event{Type: events.FlatCreated, Flat: structures.Flat{Id: 11}})
+	matcher.Handle(ctx, events.Event{Type: events.FlatApproved, Flat: structures.Flat{Id: 10}})
+	matcher.Handle(ctx, events.Event{Type: events.FlatApproved, Flat: structures.Flat{Id: 10}})
+	if len(source.queue) != 1 {
+		t.Fatalf("queue = %v, want the approved flat once", source.queue)
+	}
+
+	// a failed batch stays queued and is claimed again after the lease
+	matcher.flush(ctx)
+	if len(source.queue) != 1 || len(outbox.keys) != 0 {
+		t.Fatalf("queue = %v, sent = %v after a failed batch", source.queue, outbox.keys)
+	}
+	source.queue[10] = time.Time{}
+	source.matchErr = nil
+
+	matcher.flush(ctx)
+	if len(source.queue) != 0 {
+		t.Errorf("queue = %v, want empty after the batch is sent", source.queue)
+	}
+	if len(outbox.keys) != 1 || !strings.HasSuffix(outbox.keys[0], ":"+alice.String()) {
+		t.Errorf("sent = %v, want one alert to alice", outbox.keys)
+	}
+}
+
+func TestMatcherKeepsUndeliveredAlerts(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	source := &fakeMatchSource{
+		queue:   map[int]time.Time{10: {}, 11: {}, 12: {}},
+		matches: []structures.SearchMatch{match(alice, 1, 10), match(alice, 1, 11), match(bob, 2, 11), match(bob, 2, 12)},
+	}
+	outbox := &fakeOutbox{fail: map[string]bool{bob.String() + "@example.com": true}}
+	matcher := NewMatcher(discardLog, source, testDispatcher(t, outbox), time.Minute)
+	ctx := context.Background()
+
+	// the flats of the failed alert to bob wait for the retry
+	matcher.flush(ctx)
+	if _, ok := source.queue[10]; ok || len(source.queue) != 2 {
+		t.Fatalf("queue = %v, want flats 11 and 12 left", source.queue)
+	}
+	if len(outbox.keys) != 1 || !strings.HasSuffix(outbox.keys[0], ":"+alice.String()) {
+		t.Fatalf("sent = %v, want one alert to alice", outbox.keys)
+	}
+
+	for id := range source.queue {
+		source.queue[id] = time.Time{}
+	}
+	outbox.fail = nil
+	matcher.flush(ctx)
+	if len(source.queue) != 0 {
+		t.Errorf("queue = %v, want empty after the retry", source.queue)
+	}
+	toBob := 0
+	for _, key := range outbox.keys {
+		if strings.HasSuffix(key, ":"+bob.String()) {
+			toBob++
+		}
+	}
+	if toBob != 1 {
+		t.Errorf("sent = %v, want one alert to bob after the retry", outbox.keys)
+	}
+}
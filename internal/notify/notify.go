@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/templates"
+	"github.com/google/uuid"
+)
+
+// Outbox sends the messages reliably, internal/notifications implements it.
+// The key makes repeated sending of the same message a no-op.
+type Outbox interface {
+	Enqueue(ctx context.Context, key, recipient, message string) error
+}
+
+type dispatchStore interface {
+	SavePendingNotification(ctx context.Context, userId uuid.UUID, message string) error
+}
+
+// Dispatcher delivers notifications according to the user preference:
+// instant messages are sent at once, digest messages wait for the Digest
+// and muted users get nothing. The emails are rendered from the templates
+// in the locale of the user.
+type Dispatcher struct {
+	log       *slog.Logger
+	store     dispatchStore
+	outbox    Outbox
+	tokens    *Tokens
+	templates *templates.Renderer
+}
+
+func NewDispatcher(
+	log *slog.Logger,
+	store dispatchStore,
+	outbox Outbox,
+	tokens *Tokens,
+	renderer *templates.Renderer,
+) *Dispatcher {
+	return &Dispatcher{log: log, store: store, outbox: outbox, tokens: tokens, templates: renderer}
+}
+
+// Deliver notifies the recipient with the template, the key identifies the
+// notification of the recipient. A digest keeps the text of the email. The
+// error tells that the notification was neither sent nor kept.
+func (d *Dispatcher) Deliver(ctx context.Context, recipient structures.Recipient, key, name string, data interface{}) error {
+	const op = "notify.dispatcher.deliver"
+	log := d.log.With(slog.String("op", op), slog.String("user_id", recipient.UserId.String()))
+
+	switch recipient.Delivery {
+	case structures.DeliveryMuted:
+		return nil
+	case structures.DeliveryHourly, structures.DeliveryDaily:
+		email, err := d.templates.Render(name, recipient.Locale, data, "")
+		if err != nil {
+			log.Error("failed to render email", slog.String("template", name), slog.Any("error", err))
+			return err
+		}
+		if err = d.store.SavePendingNotification(ctx, recipient.UserId, email.Text); err != nil {
+			log.Error("failed to save pending notification", slog.Any("error", err))
+			return err
+		}
+		return nil
+	default:
+		return d.send(ctx, recipient, key, name, data, d.tokens.UnsubscribeURL(recipient.UserId))
+	}
+}
+
+// Send emails the recipient regardless of the delivery preference, it is
+// used for the emails about the user own actions.
+func (d *Dispatcher) Send(ctx context.Context, recipient structures.Recipient, key, name string, data interface{}) error {
+	return d.send(ctx, recipient, key, name, data, "")
+}
+
+// send renders the email and puts it to the outbox.
+func (d *Dispatcher) send(
+	ctx context.Context,
+	recipient structures.Recipient,
+	key, name string,
+	data interface{},
+	unsubscribeURL string,
+) error {
+	email, err := d.templates.Render(name, recipient.Locale, data, unsubscribeURL)
+	if err != nil {
+		d.log.Error("failed to render email", slog.String("template", name), slog.Any("error", err))
+		return err
+	}
+	if err = d.outbox.Enqueue(ctx, key+":"+recipient.UserId.String(), recipient.Email, email.Message()); err != nil {
+		d.log.Error("failed to enqueue email", slog.String("recipient", recipient.Email), slog.Any("error", err))
+		return err
+	}
+	return nil
+}
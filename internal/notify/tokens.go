@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// Tokens signs the unsubscribe links, so a user can unsubscribe from an
+// email without logging in.
+type Tokens struct {
+	secret  []byte
+	baseURL string
+}
+
+func NewTokens(secret, baseURL string) *Tokens {
+	return &Tokens{secret: []byte(secret), baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Sign returns the token of the user: the user id and its HMAC-SHA256.
+func (t *Tokens) Sign(userId uuid.UUID) string {
+	return userId.String() + "." + base64.RawURLEncoding.EncodeToString(t.mac(userId))
+}
+
+// Verify returns the user of a token made by Sign.
+func (t *Tokens) Verify(token string) (uuid.UUID, error) {
+	id, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, ErrInvalidToken
+	}
+	userId, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, t.mac(userId)) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	return userId, nil
+}
+
+func (t *Tokens) UnsubscribeURL(userId uuid.UUID) string {
+	return t.baseURL + "/unsubscribe?token=" + url.QueryEscape(t.Sign(userId))
+}
+
+func (t *Tokens) mac(userId uuid.UUID) []byte {
+	h := hmac.New(sha256.New, t.secret)
+	h.Write([]byte("unsubscribe:" + userId.String()))
+	return h.Sum(nil)
+}
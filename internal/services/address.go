@@ -0,0 +1,204 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+// defaultStreetType is assumed when the address has no street type, so
+// "Ленина 5" and "ул. Ленина 5" are the same house.
+const defaultStreetType = "ул"
+
+var (
+	cityMarkers = map[string]bool{"г": true, "гор": true, "город": true}
+
+	buildingMarkers = map[string]bool{"д": true, "дом": true}
+
+	// blockMarkers map the block markers to their short form, "5 к 2" and
+	// "5 стр 2" are different houses. The one letter markers are street
+	// words until the building number is found, as in "ул. С. Ковалевской".
+	blockMarkers = map[string]string{
+		"к":        "к",
+		"корп":     "к",
+		"корпус":   "к",
+		"с":        "стр",
+		"стр":      "стр",
+		"строение": "стр",
+		"лит":      "лит",
+		"литера":   "лит",
+	}
+
+	// flatMarkers name a flat or an office inside the house, the number
+	// after them is not a part of the house address.
+	flatMarkers = map[string]bool{
+		"кв": true, "квартира": true, "оф": true, "офис": true, "пом": true, "помещение": true,
+	}
+
+	streetTypes = map[string]string{
+		"ул":         "ул",
+		"улица":      "ул",
+		"пр":         "пр-кт",
+		"пр-т":       "пр-кт",
+		"пр-кт":      "пр-кт",
+		"просп":      "пр-кт",
+		"проспект":   "пр-кт",
+		"пер":        "пер",
+		"переулок":   "пер",
+		"б-р":        "б-р",
+		"бул":        "б-р",
+		"бульвар":    "б-р",
+		"ш":          "ш",
+		"шоссе":      "ш",
+		"наб":        "наб",
+		"набережная": "наб",
+		"пл":         "пл",
+		"площадь":    "пл",
+		"пр-д":       "пр-д",
+		"проезд":     "пр-д",
+		"туп":        "туп",
+		"тупик":      "туп",
+		"мкр":        "мкр",
+		"микрорайон": "мкр",
+	}
+)
+
+// ParseAddress splits a free-form address into normalized components.
+// Components are lower-cased and markers such as "ул.", "д." or "корп."
+// are recognised in any position, a flat number after "кв." is dropped.
+// A leading part without markers and numbers is the city, e.g. "Москва"
+// in "Москва, Ленина 5". The city is a part of the normalized form, so the
+// same street in two cities are different houses, an address without the
+// city is a value of its own.
+func ParseAddress(raw string) structures.Address {
+	var address structures.Address
+
+	var streetType string
+	var street []string
+	parts := strings.Split(normalizeAddressText(raw), ",")
+	if len(parts) > 1 && isPlainPart(strings.Fields(parts[0])) && hasStreet(parts[1:]) {
+		address.City = strings.Join(strings.Fields(parts[0]), " ")
+		parts = parts[1:]
+	}
+
+	for _, part := range parts {
+		expect := ""
+		for _, word := range strings.Fields(part) {
+			switch {
+			case cityMarkers[word]:
+				expect = "city"
+			case buildingMarkers[word]:
+				expect = "building"
+			case blockMarkers[word] != "" && (address.Building != "" || len(word) > len("к")):
+				expect = blockMarkers[word]
+			case flatMarkers[word]:
+				expect = "flat"
+			case streetTypes[word] != "":
+				streetType = streetTypes[word]
+				expect = ""
+			case expect == "flat":
+				expect = ""
+			case isOrdinal(word):
+				street = append(street, word)
+			case unicode.IsDigit([]rune(word)[0]):
+				switch {
+				case expect == "" && address.Building != "" && address.Block == "":
+					// "Ленина 5 2" has the block without a marker
+					address.Block = blockMarkers["корпус"] + word
+				case expect != "" && expect != "building" && expect != "city":
+					address.Block = expect + word
+				case address.Building == "":
+					address.Building, address.Block = splitBuilding(word)
+				}
+				expect = ""
+			case expect == "city":
+				if address.City != "" {
+					address.City += " "
+				}
+				address.City += word
+			case expect != "" && expect != "building":
+				address.Block = expect + word
+				expect = ""
+			default:
+				street = append(street, word)
+			}
+		}
+	}
+
+	if len(street) > 0 {
+		if streetType == "" {
+			streetType = defaultStreetType
+		}
+		address.Street = streetType + " " + strings.Join(street, " ")
+	}
+	address.Normalized = strings.Join([]string{address.City, address.Street, address.Building, address.Block}, "|")
+	return address
+}
+
+// normalizeAddressText lower-cases the address, replaces "ё" and splits
+// markers glued to numbers like "д.5".
+func normalizeAddressText(raw string) string {
+	text := strings.ToLower(raw)
+	text = strings.ReplaceAll(text, "ё", "е")
+	text = strings.NewReplacer(".", " ", ";", ",", "№", " ").Replace(text)
+	return text
+}
+
+// hasStreet reports whether any of the parts has a word of a street name.
+func hasStreet(parts []string) bool {
+	for _, part := range parts {
+		for _, word := range strings.Fields(part) {
+			if isPlainWord(word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isPlainPart(words []string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	for _, word := range words {
+		if !isPlainWord(word) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPlainWord reports whether the word is neither a marker nor a number.
+func isPlainWord(word string) bool {
+	if cityMarkers[word] || buildingMarkers[word] || blockMarkers[word] != "" || flatMarkers[word] ||
+		streetTypes[word] != "" {
+		return false
+	}
+	return !strings.ContainsFunc(word, unicode.IsDigit)
+}
+
+// isOrdinal reports whether the word is a numeral part of a street name
+// such as "1-я".
+func isOrdinal(word string) bool {
+	i := strings.Index(word, "-")
+	return i > 0 && unicode.IsDigit([]rune(word)[0]) && i+1 < len(word) &&
+		!strings.ContainsFunc(word[i+1:], unicode.IsDigit)
+}
+
+// splitBuilding separates a block glued to the building number, e.g.
+// "5к2" or "5стр2".
+func splitBuilding(word string) (string, string) {
+	for _, marker := range []string{"корп", "стр", "к", "с"} {
+		i := strings.Index(word, marker)
+		if i <= 0 {
+			continue
+		}
+		block := word[i+len(marker):]
+		if block == "" || !unicode.IsDigit([]rune(block)[0]) {
+			continue
+		}
+		return word[:i], blockMarkers[marker] + block
+	}
+	return word, ""
+}
@@ -0,0 +1,63 @@
+package services
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		raw        string
+		city       string
+		street     string
+		building   string
+		block      string
+		normalized string
+	}{
+		{raw: "ул. Ленина 5", street: "ул ленина", building: "5", normalized: "|ул ленина|5|"},
+		{raw: "Ленина ул., д.5", street: "ул ленина", building: "5", normalized: "|ул ленина|5|"},
+		{raw: "Ленина 5", street: "ул ленина", building: "5", normalized: "|ул ленина|5|"},
+		{
+			raw: "Москва, Ленина 5", city: "москва", street: "ул ленина", building: "5",
+			normalized: "москва|ул ленина|5|",
+		},
+		{
+			raw: "г. Москва, улица Ленина, дом 5", city: "москва", street: "ул ленина", building: "5",
+			normalized: "москва|ул ленина|5|",
+		},
+		{raw: "Казань, Ленина 5", city: "казань", street: "ул ленина", building: "5", normalized: "казань|ул ленина|5|"},
+		{raw: "ул. Ленина 5, кв 12", street: "ул ленина", building: "5", normalized: "|ул ленина|5|"},
+		{raw: "Ленина 5, квартира 12", street: "ул ленина", building: "5", normalized: "|ул ленина|5|"},
+		{raw: "Ленина 5 с 2", street: "ул ленина", building: "5", block: "стр2", normalized: "|ул ленина|5|стр2"},
+		{raw: "Ленина 5с2", street: "ул ленина", building: "5", block: "стр2", normalized: "|ул ленина|5|стр2"},
+		{raw: "Ленина д. 5, стр. 2", street: "ул ленина", building: "5", block: "стр2", normalized: "|ул ленина|5|стр2"},
+		{raw: "Ленина 5 к 2", street: "ул ленина", building: "5", block: "к2", normalized: "|ул ленина|5|к2"},
+		{raw: "Ленина 5к2", street: "ул ленина", building: "5", block: "к2", normalized: "|ул ленина|5|к2"},
+		{raw: "Ленина 5, корпус 2, кв. 7", street: "ул ленина", building: "5", block: "к2", normalized: "|ул ленина|5|к2"},
+		{raw: "Ленина 5 2", street: "ул ленина", building: "5", block: "к2", normalized: "|ул ленина|5|к2"},
+		{raw: "Ленина 5 лит. А", street: "ул ленина", building: "5", block: "лита", normalized: "|ул ленина|5|лита"},
+		{
+			raw: "ул. С. Ковалевской, 3", street: "ул с ковалевской", building: "3",
+			normalized: "|ул с ковалевской|3|",
+		},
+		{
+			raw: "Проспект Мира 10А", street: "пр-кт мира", building: "10а",
+			normalized: "|пр-кт мира|10а|",
+		},
+		{
+			raw: "1-я Тверская-Ямская ул., 15", street: "ул 1-я тверская-ямская", building: "15",
+			normalized: "|ул 1-я тверская-ямская|15|",
+		},
+		{raw: "Ёлочная 3", street: "ул елочная", building: "3", normalized: "|ул елочная|3|"},
+		{raw: "д. 5", building: "5", normalized: "||5|"},
+	}
+	for _, tt := range tests {
+		address := ParseAddress(tt.raw)
+		if address.City != tt.city || address.Street != tt.street || address.Building != tt.building ||
+			address.Block != tt.block || address.Normalized != tt.normalized {
+			t.Errorf(
+				"ParseAddress(%q) = %q|%q|%q|%q (%q), want %q|%q|%q|%q (%q)",
+				tt.raw,
+				address.City, address.Street, address.Building, address.Block, address.Normalized,
+				tt.city, tt.street, tt.building, tt.block, tt.normalized,
+			)
+		}
+	}
+}
@@ -11,7 +11,7 @@ import (
 func MakeErrorResponse(
 	w http.ResponseWriter, r *http.Request, log *slog.Logger, str string, code int, requestId string, err error,
 ) {
-	log.Error(str, err)
+	log.Error(str, slog.Any("error", err))
 	w.WriteHeader(code)
 	render.JSON(w, r, response.MakeResponse(str, requestId, code))
 }
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+var flatStatuses = map[string]bool{
+	"created":       true,
+	"approved":      true,
+	"declined":      true,
+	"on moderation": true,
+}
+
+// ParseFlatFilter reads the flat list query parameters, the status
+// and priority filters are accepted from moderators only.
+func ParseFlatFilter(values url.Values, moderator bool) (structures.FlatFilter, error) {
+	var filter structures.FlatFilter
+	var err error
+
+	if filter.PriceFrom, err = QueryInt(values, "price_from"); err != nil {
+		return filter, err
+	}
+	if filter.PriceTo, err = QueryInt(values, "price_to"); err != nil {
+		return filter, err
+	}
+	if filter.RoomsFrom, err = QueryInt(values, "rooms_from"); err != nil {
+		return filter, err
+	}
+	if filter.RoomsTo, err = QueryInt(values, "rooms_to"); err != nil {
+		return filter, err
+	}
+
+	filter.Status = values.Get("status")
+	if filter.Status != "" {
+		if !moderator {
+			return filter, fmt.Errorf("status filter is available to moderators only")
+		}
+		if !flatStatuses[filter.Status] {
+			return filter, fmt.Errorf("invalid status parameter")
+		}
+	}
+
+	if filter.Priority, err = QueryBool(values, "priority"); err != nil {
+		return filter, err
+	}
+	if filter.Priority && !moderator {
+		return filter, fmt.Errorf("priority filter is available to moderators only")
+	}
+
+	filter.SortBy = values.Get("sort")
+	switch filter.SortBy {
+	case "":
+		filter.SortBy = "id"
+	case "id", "price", "rooms":
+	default:
+		return filter, fmt.Errorf("invalid sort parameter")
+	}
+	if filter.Desc, err = QueryOrder(values, false); err != nil {
+		return filter, err
+	}
+	if filter.Limit, err = QueryLimit(values); err != nil {
+		return filter, err
+	}
+
+	if str := values.Get("cursor"); str != "" {
+		if filter.After, err = DecodeCursor(str); err != nil {
+			return filter, err
+		}
+		if _, err = strconv.Atoi(filter.After.Value); err != nil {
+			return filter, fmt.Errorf("invalid cursor")
+		}
+	}
+	return filter, nil
+}
+
+// FlatCursor builds the cursor pointing after the flat for the given sorting.
+func FlatCursor(flat structures.Flat, sortBy string) structures.Cursor {
+	value := flat.Id
+	switch sortBy {
+	case "price":
+		value = flat.Price
+	case "rooms":
+		value = flat.Rooms
+	}
+	return structures.Cursor{Value: strconv.Itoa(value), Id: flat.Id}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+type GetListResponse struct {
+	Flats      *[]structures.Flat `json:"flats"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// Page is a serialized list response with the hash of its content, the
+// hash is the ETag of the response.
+type Page struct {
+	Body []byte
+	ETag string
+}
+
+// NewPage serializes the flats of the query with the filter, the rows past
+// the limit of the page only set the next cursor.
+func NewPage(flats *[]structures.Flat, filter structures.FlatFilter) (*Page, error) {
+	limit := filter.Limit - 1
+	listResponse := GetListResponse{Flats: flats}
+	if len(*flats) > limit {
+		page := (*flats)[:limit]
+		listResponse.Flats = &page
+		listResponse.NextCursor = EncodeCursor(FlatCursor(page[limit-1], filter.SortBy))
+	}
+
+	body, err := json.Marshal(&listResponse)
+	if err != nil {
+		return nil, err
+	}
+	return &Page{Body: body, ETag: ETag(body)}, nil
+}
+
+// ETag is the strong entity tag of the response body.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
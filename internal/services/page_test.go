@@ -0,0 +1,22 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+func TestNewPageSetsNextCursor(t *testing.T) {
+	flats := []structures.Flat{{Id: 1}, {Id: 2}, {Id: 3}}
+	page, err := NewPage(&flats, structures.FlatFilter{Limit: 3})
+	if err != nil {
+		t.Fatalf("NewPage: %v", err)
+	}
+	if page.ETag != ETag(page.Body) {
+		t.Errorf("ETag %s does not match the body", page.ETag)
+	}
+	want := `{"flats":[{"id":1,"price_dropped":false},{"id":2,"price_dropped":false}],"next_cursor":`
+	if got := string(page.Body); len(got) < len(want) || got[:len(want)] != want {
+		t.Errorf("body = %s, want the first 2 flats and a cursor", got)
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// QueryInt returns the integer query parameter or 0 if it is absent.
+func QueryInt(values url.Values, key string) (int, error) {
+	str := values.Get(key)
+	if str == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(str)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid %s parameter", key)
+	}
+	return value, nil
+}
+
+// QueryFloat returns the float query parameter, ok is false if it is absent.
+func QueryFloat(values url.Values, key string) (value float64, ok bool, err error) {
+	str := values.Get(key)
+	if str == "" {
+		return 0, false, nil
+	}
+	value, err = strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s parameter", key)
+	}
+	return value, true, nil
+}
+
+func QueryBool(values url.Values, key string) (bool, error) {
+	str := values.Get(key)
+	if str == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(str)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s parameter", key)
+	}
+	return value, nil
+}
+
+// QueryLimit returns the page size, DefaultLimit if it is not set.
+func QueryLimit(values url.Values) (int, error) {
+	limit, err := QueryInt(values, "limit")
+	if err != nil {
+		return 0, err
+	}
+	if limit == 0 {
+		return DefaultLimit, nil
+	}
+	if limit > MaxLimit {
+		return 0, fmt.Errorf("limit must not exceed %d", MaxLimit)
+	}
+	return limit, nil
+}
+
+// QueryOrder reports whether the order parameter asks for descending sort.
+func QueryOrder(values url.Values, desc bool) (bool, error) {
+	switch values.Get("order") {
+	case "":
+		return desc, nil
+	case "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid order parameter")
+	}
+}
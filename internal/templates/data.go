@@ -0,0 +1,69 @@
+package templates
+
+import "time"
+
+type SubscriptionFlat struct {
+	FlatId  int
+	Address string
+	Rooms   int
+	Price   int
+}
+
+type SubscriptionData struct {
+	Flats []SubscriptionFlat
+}
+
+type PriceChangedData struct {
+	FlatId   int
+	OldPrice int
+	NewPrice int
+}
+
+type DigestItem struct {
+	At   time.Time
+	Text string
+}
+
+type DigestData struct {
+	Items []DigestItem
+}
+
+type LinkData struct {
+	Link string
+}
+
+type ModerationOutcomeData struct {
+	FlatId   int
+	Approved bool
+	Reason   string
+}
+
+// Sample returns the example data of the template for the previews.
+func Sample(name string) (interface{}, bool) {
+	switch name {
+	case Subscription:
+		return SubscriptionData{
+			Flats: []SubscriptionFlat{
+				{FlatId: 12, Address: "Москва, ул. Ленина, д. 5", Rooms: 2, Price: 12500000},
+				{FlatId: 47, Address: "Москва, пр-т Мира, д. 101", Rooms: 3, Price: 18900000},
+			},
+		}, true
+	case PriceChanged:
+		return PriceChangedData{FlatId: 12, OldPrice: 12500000, NewPrice: 11900000}, true
+	case Digest:
+		at := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+		return DigestData{
+			Items: []DigestItem{
+				{At: at, Text: "Цена квартиры 12 из избранного изменилась: 12500000 ₽ → 11900000 ₽."},
+				{At: at.Add(time.Hour), Text: "Появились новые квартиры, подходящие под ваши сохранённые поиски."},
+			},
+		}, true
+	case Verification:
+		return LinkData{Link: "https://example.com/verify?token=sample"}, true
+	case PasswordReset:
+		return LinkData{Link: "https://example.com/reset?token=sample"}, true
+	case ModerationOutcome:
+		return ModerationOutcomeData{FlatId: 12, Approved: false, Reason: "banned words in the description"}, true
+	}
+	return nil, false
+}
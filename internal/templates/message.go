@@ -0,0 +1,37 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+)
+
+// Message returns the email as a MIME message with the text and the html
+// alternatives, the sender takes it as is.
+func (e *Email) Message() string {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	e.writePart(w, "text/plain; charset=utf-8", e.Text)
+	e.writePart(w, "text/html; charset=utf-8", e.HTML)
+	_ = w.Close()
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("utf-8", e.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", w.Boundary())
+	b.Write(body.Bytes())
+	return b.String()
+}
+
+func (e *Email) writePart(w *multipart.Writer, contentType, content string) {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	part, _ := w.CreatePart(header)
+	qp := quotedprintable.NewWriter(part)
+	_, _ = qp.Write([]byte(content))
+	_ = qp.Close()
+}
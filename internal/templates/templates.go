@@ -0,0 +1,134 @@
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed emails
+var files embed.FS
+
+// Email templates. Subscription, price changes and digests are the
+// notifications users subscribe to, the others are sent on their actions.
+const (
+	Subscription      = "subscription"
+	PriceChanged      = "price_changed"
+	Digest            = "digest"
+	Verification      = "verification"
+	PasswordReset     = "password_reset"
+	ModerationOutcome = "moderation_outcome"
+)
+
+const (
+	LocaleRu      = "ru"
+	LocaleEn      = "en"
+	DefaultLocale = LocaleRu
+)
+
+var (
+	Names   = []string{Subscription, PriceChanged, Digest, Verification, PasswordReset, ModerationOutcome}
+	Locales = []string{LocaleRu, LocaleEn}
+)
+
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+var funcs = map[string]interface{}{
+	"date": func(t time.Time) string {
+		return t.Format("02.01.2006 15:04")
+	},
+}
+
+type Email struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+// view is the data of a template: the template data and the unsubscribe
+// link, which is empty for the emails users do not subscribe to.
+type view struct {
+	Data           interface{}
+	UnsubscribeURL string
+	Subject        string
+}
+
+type key struct {
+	name   string
+	locale string
+}
+
+// Renderer renders the embedded templates, every template has a text
+// variant defining "subject" and "text" and an html variant defining
+// "content" placed into the layout of the locale.
+type Renderer struct {
+	text map[key]*texttemplate.Template
+	html map[key]*htmltemplate.Template
+}
+
+func New() (*Renderer, error) {
+	r := &Renderer{
+		text: make(map[key]*texttemplate.Template),
+		html: make(map[key]*htmltemplate.Template),
+	}
+	for _, locale := range Locales {
+		for _, name := range Names {
+			dir := "emails/" + locale + "/"
+			text, err := texttemplate.New(name).Funcs(funcs).ParseFS(files, dir+"layout.txt", dir+name+".txt")
+			if err != nil {
+				return nil, fmt.Errorf("parse %s/%s.txt: %w", locale, name, err)
+			}
+			html, err := htmltemplate.New(name).Funcs(funcs).ParseFS(files, dir+"layout.html", dir+name+".html")
+			if err != nil {
+				return nil, fmt.Errorf("parse %s/%s.html: %w", locale, name, err)
+			}
+			r.text[key{name, locale}] = text
+			r.html[key{name, locale}] = html
+		}
+	}
+	return r, nil
+}
+
+// Render renders the template in the locale, an unknown locale falls back
+// to the default one.
+func (r *Renderer) Render(name, locale string, data interface{}, unsubscribeURL string) (*Email, error) {
+	if _, ok := r.text[key{name, locale}]; !ok {
+		locale = DefaultLocale
+	}
+	text, ok := r.text[key{name, locale}]
+	if !ok {
+		return nil, ErrUnknownTemplate
+	}
+	html := r.html[key{name, locale}]
+
+	v := view{Data: data, UnsubscribeURL: unsubscribeURL}
+	subject, err := execute(text, "subject", v)
+	if err != nil {
+		return nil, err
+	}
+	v.Subject = strings.TrimSpace(subject)
+
+	body, err := execute(text, "text", v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = html.ExecuteTemplate(&buf, "layout", v); err != nil {
+		return nil, fmt.Errorf("render %s html: %w", name, err)
+	}
+	return &Email{Subject: v.Subject, Text: strings.TrimSpace(body), HTML: buf.String()}, nil
+}
+
+func execute(t *texttemplate.Template, name string, v view) (string, error) {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, v); err != nil {
+		return "", fmt.Errorf("render %s %s: %w", t.Name(), name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,78 @@
+package templates
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRenderAll(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	const unsubscribeURL = "https://example.com/unsubscribe?token=sample"
+	for _, name := range Names {
+		data, ok := Sample(name)
+		if !ok {
+			t.Errorf("no sample data for %s", name)
+			continue
+		}
+		for _, locale := range Locales {
+			t.Run(locale+"/"+name, func(t *testing.T) {
+				email, err := r.Render(name, locale, data, unsubscribeURL)
+				if err != nil {
+					t.Fatalf("Render: %v", err)
+				}
+				if email.Subject == "" || strings.Contains(email.Subject, "\n") {
+					t.Errorf("subject = %q, want a single line", email.Subject)
+				}
+				if email.Text == "" {
+					t.Error("text is empty")
+				}
+				if !strings.Contains(email.HTML, "<html") {
+					t.Errorf("html is not placed into the layout: %q", email.HTML)
+				}
+				for _, body := range []string{email.Text, email.HTML} {
+					if strings.Contains(body, "<no value>") {
+						t.Errorf("body refers to missing data: %q", body)
+					}
+				}
+				if message := email.Message(); !strings.Contains(message, "multipart/alternative") {
+					t.Errorf("message is not multipart: %q", message)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderFallsBackToDefaultLocale(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	data, _ := Sample(PriceChanged)
+
+	fallback, err := r.Render(PriceChanged, "de", data, "")
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	def, err := r.Render(PriceChanged, DefaultLocale, data, "")
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if fallback.Subject != def.Subject {
+		t.Errorf("subject = %q, want the default locale %q", fallback.Subject, def.Subject)
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err = r.Render("missing", LocaleEn, nil, ""); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("Render = %v, want %v", err, ErrUnknownTemplate)
+	}
+}
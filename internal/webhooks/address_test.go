@@ -0,0 +1,94 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url       string
+		forbidden bool
+	}{
+		{url: "http://127.0.0.1:8082/hook", forbidden: true},
+		{url: "http://10.1.2.3/hook", forbidden: true},
+		{url: "http://172.16.0.1/hook", forbidden: true},
+		{url: "http://192.168.1.1/hook", forbidden: true},
+		{url: "http://169.254.169.254/latest/meta-data", forbidden: true},
+		{url: "http://100.64.0.1/hook", forbidden: true},
+		{url: "http://0.0.0.0/hook", forbidden: true},
+		{url: "http://[::1]/hook", forbidden: true},
+		{url: "http://[fe80::1]/hook", forbidden: true},
+		{url: "http://[fd00::1]/hook", forbidden: true},
+		{url: "http://[::ffff:127.0.0.1]/hook", forbidden: true},
+		{url: "https://93.184.216.34/hook"},
+		{url: "http://[2606:2800:220:1:248:1893:25c8:1946]/hook"},
+	}
+	for _, tt := range tests {
+		err := CheckURL(context.Background(), tt.url)
+		if tt.forbidden && !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckURL(%q) = %v, want ErrForbiddenAddress", tt.url, err)
+		}
+		if !tt.forbidden && err != nil {
+			t.Errorf("CheckURL(%q) = %v, want nil", tt.url, err)
+		}
+	}
+
+	if err := CheckURL(context.Background(), "ftp://93.184.216.34/hook"); err == nil {
+		t.Error("CheckURL accepted an ftp url")
+	}
+}
+
+func TestDeliveryRefusesInternalAddress(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				requests++
+			},
+		),
+	)
+	defer server.Close()
+
+	d := New(slog.New(slog.NewTextHandler(io.Discard, nil)), &fakeStore{}, Options{Timeout: time.Second})
+	_, err := d.send(context.Background(), &structures.WebhookDelivery{Url: server.URL, Payload: "{}"})
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("send to loopback: err = %v, want ErrForbiddenAddress", err)
+	}
+	if requests != 0 {
+		t.Errorf("loopback server got %d requests", requests)
+	}
+}
+
+func TestDeliveryDoesNotFollowRedirects(t *testing.T) {
+	redirected := false
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/internal" {
+					redirected = true
+					return
+				}
+				http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+			},
+		),
+	)
+	defer server.Close()
+
+	d := newDispatcher(&fakeStore{})
+	code, err := d.send(context.Background(), &structures.WebhookDelivery{Url: server.URL + "/hook", Payload: "{}"})
+	if err == nil || code == nil || *code != http.StatusTemporaryRedirect {
+		t.Errorf("send = %v, %v, want a failed delivery with the redirect status", code, err)
+	}
+	if redirected {
+		t.Error("the redirect was followed")
+	}
+}
@@ -0,0 +1,200 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+)
+
+// Headers of a delivery request. The signature is the hex HMAC-SHA256 of the
+// body with the webhook secret, prefixed with "sha256=".
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// maxErrorLength bounds the error text kept in the delivery log.
+const maxErrorLength = 500
+
+type store interface {
+	EnqueueWebhookDeliveries(ctx context.Context, event string, payload []byte) error
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) (*[]structures.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery structures.WebhookDelivery) error
+}
+
+type Options struct {
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// Dispatcher stores the events for the subscribed webhooks and delivers
+// them, a failed delivery is retried with an exponential backoff until it
+// runs out of attempts and becomes dead.
+type Dispatcher struct {
+	log    *slog.Logger
+	store  store
+	client *http.Client
+	opts   Options
+	now    func() time.Time
+}
+
+func New(log *slog.Logger, store store, opts Options) *Dispatcher {
+	return &Dispatcher{
+		log:    log,
+		store:  store,
+		client: newClient(opts.Timeout),
+		opts:   opts,
+		now:    time.Now,
+	}
+}
+
+// eventPayload is the body of a delivery. The subscribers are outside of
+// the service, so the moderator who claimed the flat is left out.
+type eventPayload struct {
+	Type      events.Type     `json:"type"`
+	Flat      structures.Flat `json:"flat"`
+	OldPrice  int             `json:"old_price,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Handle enqueues the event for the webhooks subscribed to it.
+func (d *Dispatcher) Handle(ctx context.Context, event events.Event) {
+	payload, err := json.Marshal(
+		eventPayload{Type: event.Type, Flat: event.Flat, OldPrice: event.OldPrice, CreatedAt: event.CreatedAt},
+	)
+	if err != nil {
+		d.log.Error("failed to marshal webhook payload", slog.Any("error", err))
+		return
+	}
+	if err = d.store.EnqueueWebhookDeliveries(ctx, string(event.Type), payload); err != nil {
+		d.log.Error("failed to enqueue webhook deliveries", slog.Any("error", err))
+	}
+}
+
+// Run delivers the due deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.deliverDue(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	// the lease covers the attempts of the whole batch
+	lease := d.opts.Timeout*time.Duration(d.opts.BatchSize) + d.opts.PollInterval
+	deliveries, err := d.store.ClaimWebhookDeliveries(ctx, d.opts.BatchSize, lease)
+	if err != nil {
+		d.log.Error("failed to claim webhook deliveries", slog.Any("error", err))
+		return
+	}
+
+	for _, delivery := range *deliveries {
+		d.attempt(ctx, &delivery)
+		if err = d.store.UpdateWebhookDelivery(ctx, delivery); err != nil {
+			d.log.Error("failed to update webhook delivery", slog.Any("error", err))
+		}
+	}
+}
+
+// attempt sends the delivery once and records the outcome in it.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *structures.WebhookDelivery) {
+	delivery.Attempts++
+	code, err := d.send(ctx, delivery)
+	delivery.ResponseCode = code
+	if err == nil {
+		delivery.Status = structures.DeliveryDelivered
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if len(delivery.LastError) > maxErrorLength {
+		delivery.LastError = delivery.LastError[:maxErrorLength]
+	}
+	if delivery.Attempts >= d.opts.MaxAttempts {
+		delivery.Status = structures.DeliveryDead
+		d.log.Warn(
+			"webhook delivery is dead",
+			slog.Int("delivery_id", delivery.Id),
+			slog.Int("webhook_id", delivery.WebhookId),
+		)
+		return
+	}
+	delivery.Status = structures.DeliveryPending
+	delivery.NextAttemptAt = d.now().Add(d.backoff(delivery.Attempts))
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *structures.WebhookDelivery) (*int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.Id))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	code := resp.StatusCode
+	if code < 200 || code > 299 {
+		return &code, fmt.Errorf("unexpected status code %d", code)
+	}
+	return &code, nil
+}
+
+// backoff returns the delay after the attempt: the base delay doubled for
+// every failed attempt, capped by the max delay.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.opts.MaxBackoff {
+			return d.opts.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// Sign returns the signature header value of the body.
+func Sign(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify checks the signature header value of the body, receivers in Go can
+// use it as is.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
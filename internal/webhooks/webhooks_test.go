@@ -0,0 +1,196 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/datasource/storage/structures"
+	"github.com/dugtriol/backend-bootcamp-assignment-2024/internal/events"
+	"github.com/google/uuid"
+)
+
+type fakeStore struct {
+	mu       sync.Mutex
+	pending  []structures.WebhookDelivery
+	updated  []structures.WebhookDelivery
+	enqueued []string
+	payloads [][]byte
+}
+
+func (s *fakeStore) EnqueueWebhookDeliveries(_ context.Context, event string, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enqueued = append(s.enqueued, event)
+	s.payloads = append(s.payloads, payload)
+	return nil
+}
+
+func (s *fakeStore) ClaimWebhookDeliveries(
+	_ context.Context, _ int, _ time.Duration,
+) (*[]structures.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := s.pending
+	s.pending = nil
+	return &claimed, nil
+}
+
+func (s *fakeStore) UpdateWebhookDelivery(_ context.Context, delivery structures.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updated = append(s.updated, delivery)
+	return nil
+}
+
+func newDispatcher(store *fakeStore) *Dispatcher {
+	d := New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		store,
+		Options{
+			MaxAttempts:  3,
+			BaseBackoff:  time.Second,
+			MaxBackoff:   3 * time.Second,
+			Timeout:      time.Second,
+			PollInterval: time.Second,
+			BatchSize:    10,
+		},
+	)
+	d.now = func() time.Time { return time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC) }
+	// the test servers listen on loopback, which the delivery client refuses
+	d.client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	return d
+}
+
+func TestDeliverSignsPayload(t *testing.T) {
+	const secret = "secret"
+	payload := `{"type":"flat.approved"}`
+
+	var gotEvent, gotSignature, gotBody string
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				gotBody = string(body)
+				gotEvent = r.Header.Get(HeaderEvent)
+				gotSignature = r.Header.Get(HeaderSignature)
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer server.Close()
+
+	store := &fakeStore{
+		pending: []structures.WebhookDelivery{
+			{Id: 1, WebhookId: 1, Event: "flat.approved", Payload: payload, Url: server.URL, Secret: secret},
+		},
+	}
+	newDispatcher(store).deliverDue(context.Background())
+
+	if gotBody != payload {
+		t.Fatalf("body = %q, want %q", gotBody, payload)
+	}
+	if gotEvent != "flat.approved" {
+		t.Fatalf("event header = %q", gotEvent)
+	}
+	if !Verify(secret, []byte(gotBody), gotSignature) {
+		t.Fatalf("signature %q does not verify", gotSignature)
+	}
+	if len(store.updated) != 1 {
+		t.Fatalf("updated %d deliveries, want 1", len(store.updated))
+	}
+	delivery := store.updated[0]
+	if delivery.Status != structures.DeliveryDelivered || delivery.Attempts != 1 {
+		t.Fatalf("delivery = %s after %d attempts", delivery.Status, delivery.Attempts)
+	}
+	if delivery.ResponseCode == nil || *delivery.ResponseCode != http.StatusNoContent {
+		t.Fatalf("response code = %v", delivery.ResponseCode)
+	}
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+		),
+	)
+	defer server.Close()
+
+	d := newDispatcher(&fakeStore{})
+	delivery := structures.WebhookDelivery{Id: 1, Url: server.URL, Secret: "secret"}
+
+	for attempt, wantDelay := range []time.Duration{time.Second, 2 * time.Second} {
+		d.attempt(context.Background(), &delivery)
+		if delivery.Status != structures.DeliveryPending {
+			t.Fatalf("attempt %d: status = %s, want pending", attempt+1, delivery.Status)
+		}
+		if got := delivery.NextAttemptAt.Sub(d.now()); got != wantDelay {
+			t.Fatalf("attempt %d: next attempt in %s, want %s", attempt+1, got, wantDelay)
+		}
+		if delivery.LastError == "" {
+			t.Fatalf("attempt %d: last error is empty", attempt+1)
+		}
+	}
+
+	d.attempt(context.Background(), &delivery)
+	if delivery.Status != structures.DeliveryDead {
+		t.Fatalf("status = %s after %d attempts, want dead", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestDeliverUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	d := newDispatcher(&fakeStore{})
+	delivery := structures.WebhookDelivery{Id: 1, Url: url, Secret: "secret"}
+	d.attempt(context.Background(), &delivery)
+
+	if delivery.Status != structures.DeliveryPending || delivery.ResponseCode != nil {
+		t.Fatalf("delivery = %s with code %v", delivery.Status, delivery.ResponseCode)
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	d := newDispatcher(&fakeStore{})
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}
+	for i, delay := range want {
+		if got := d.backoff(i + 1); got != delay {
+			t.Fatalf("backoff(%d) = %s, want %s", i+1, got, delay)
+		}
+	}
+}
+
+func TestHandleEnqueuesEvent(t *testing.T) {
+	store := &fakeStore{}
+	moderatorId := uuid.New()
+	event := events.New(events.FlatClaimed, structures.Flat{Id: 7, Status: "on moderation", ModeratorId: &moderatorId})
+	newDispatcher(store).Handle(context.Background(), event)
+
+	if len(store.enqueued) != 1 || store.enqueued[0] != string(events.FlatClaimed) {
+		t.Fatalf("enqueued = %v", store.enqueued)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(store.payloads[0], &payload); err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+	if payload["type"] != string(events.FlatClaimed) {
+		t.Errorf("payload type = %v, want %s", payload["type"], events.FlatClaimed)
+	}
+	if _, ok := payload["moderator_id"]; ok {
+		t.Error("payload leaks the moderator id")
+	}
+	if strings.Contains(string(store.payloads[0]), moderatorId.String()) {
+		t.Error("payload contains the moderator id")
+	}
+}
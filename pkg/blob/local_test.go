@@ -0,0 +1,63 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocal(dir, "http://localhost/photos/")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	ctx := context.Background()
+
+	if err = store.Put(ctx, "flats/1/a.jpg", strings.NewReader("jpeg"), 4, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "flats", "1", "a.jpg")); err != nil {
+		t.Errorf("object is not stored under the directory: %v", err)
+	}
+
+	body, err := store.Get(ctx, "flats/1/a.jpg")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "jpeg" {
+		t.Errorf("Get = %q, want %q", data, "jpeg")
+	}
+
+	if err = store.Delete(ctx, "flats/1/a.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err = store.Get(ctx, "flats/1/a.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a deleted key: err = %v, want ErrNotFound", err)
+	}
+	if err = store.Delete(ctx, "flats/1/a.jpg"); err != nil {
+		t.Errorf("Delete of a missing key: %v", err)
+	}
+
+	if got := store.URL("flats/1/a.jpg"); got != "http://localhost/photos/flats/1/a.jpg" {
+		t.Errorf("URL = %q", got)
+	}
+}
+
+func TestLocalRejectsEscapingKeys(t *testing.T) {
+	store, err := NewLocal(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	for _, key := range []string{"", "/", "../a.jpg", "flats/../../a.jpg"} {
+		if err = store.Put(context.Background(), key, strings.NewReader("jpeg"), 4, ""); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "us-east-1"
+)
+
+// fakeS3 is a minimal path-style bucket verifying the signature of every
+// request the way S3 does.
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	paths   []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, r.Method+" "+r.URL.EscapedPath())
+
+	if err := verifySignature(r); err != nil {
+		f.t.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// verifySignature recomputes the AWS Signature Version 4 of the received
+// request with the test credentials.
+func verifySignature(r *http.Request) error {
+	amzDate := r.Header.Get("X-Amz-Date")
+	if _, err := time.Parse("20060102T150405Z", amzDate); err != nil {
+		return errors.New("missing or invalid X-Amz-Date")
+	}
+	if r.Header.Get("X-Amz-Content-Sha256") != unsignedPayload {
+		return errors.New("missing X-Amz-Content-Sha256")
+	}
+
+	scope := amzDate[:8] + "/" + testRegion + "/s3/aws4_request"
+	prefix := "AWS4-HMAC-SHA256 Credential=" + testAccessKey + "/" + scope +
+		", SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return errors.New("unexpected Authorization header: " + auth)
+	}
+
+	canonicalRequest := r.Method + "\n" +
+		r.URL.EscapedPath() + "\n" +
+		r.URL.RawQuery + "\n" +
+		"host:" + r.Host + "\n" +
+		"x-amz-content-sha256:" + unsignedPayload + "\n" +
+		"x-amz-date:" + amzDate + "\n" +
+		"\n" +
+		"host;x-amz-content-sha256;x-amz-date\n" +
+		unsignedPayload
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{amzDate[:8], testRegion, "s3", "aws4_request"} {
+		key = testHMAC(key, part)
+	}
+	want := hex.EncodeToString(testHMAC(key, stringToSign))
+	if got := strings.TrimPrefix(auth, prefix); got != want {
+		return errors.New("signature " + got + " does not match " + want)
+	}
+	return nil
+}
+
+func testHMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	fake := &fakeS3{t: t, objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := NewS3(server.URL+"/", "photos", testRegion, testAccessKey, testSecretKey, "")
+	ctx := context.Background()
+	key := "flats/1/фото 1.jpg"
+
+	if err := store.Put(ctx, key, strings.NewReader("jpeg"), 4, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "jpeg" {
+		t.Errorf("Get = %q, want %q", data, "jpeg")
+	}
+
+	if err = store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err = store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a deleted key: err = %v, want ErrNotFound", err)
+	}
+
+	path := "/photos/flats/1/%D1%84%D0%BE%D1%82%D0%BE%201.jpg"
+	want := []string{"PUT " + path, "GET " + path, "DELETE " + path, "GET " + path}
+	if strings.Join(fake.paths, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %q, want %q", fake.paths, want)
+	}
+	if got := store.URL("flats/1/a.jpg"); got != server.URL+"/photos/flats/1/a.jpg" {
+		t.Errorf("URL = %q", got)
+	}
+}
+
+func TestS3ReportsErrors(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "AccessDenied", http.StatusForbidden)
+			},
+		),
+	)
+	defer server.Close()
+
+	store := NewS3(server.URL, "photos", testRegion, testAccessKey, testSecretKey, "")
+	err := store.Put(context.Background(), "a.jpg", strings.NewReader("jpeg"), 4, "image/jpeg")
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Put: err = %v, want the status and the body", err)
+	}
+}
@@ -0,0 +1,46 @@
+package cachestore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+)
+
+// Bigcache keeps the entries in the process memory without GC overhead.
+type Bigcache struct {
+	cache *bigcache.BigCache
+}
+
+func NewBigcache(ctx context.Context, ttl time.Duration) (*Bigcache, error) {
+	cache, err := bigcache.New(ctx, bigcache.DefaultConfig(ttl))
+	if err != nil {
+		return nil, err
+	}
+	return &Bigcache{cache: cache}, nil
+}
+
+func (s *Bigcache) Get(_ context.Context, key string) ([]byte, error) {
+	value, err := s.cache.Get(key)
+	if errors.Is(err, bigcache.ErrEntryNotFound) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *Bigcache) Set(_ context.Context, key string, value []byte) error {
+	return s.cache.Set(key, value)
+}
+
+func (s *Bigcache) Delete(_ context.Context, key string) error {
+	err := s.cache.Delete(key)
+	if errors.Is(err, bigcache.ErrEntryNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *Bigcache) Reset(context.Context) error {
+	return s.cache.Reset()
+}
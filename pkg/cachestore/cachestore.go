@@ -0,0 +1,20 @@
+package cachestore
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("cache entry not found")
+
+// Store keeps cached values under string keys, the entries expire after the
+// TTL the store was created with.
+type Store interface {
+	// Get returns ErrNotFound for missing and expired entries.
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte) error
+	// Delete does nothing for missing entries.
+	Delete(ctx context.Context, key string) error
+	// Reset drops all the entries of the store.
+	Reset(ctx context.Context) error
+}
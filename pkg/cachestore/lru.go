@@ -0,0 +1,93 @@
+package cachestore
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU keeps up to size entries in the process memory and evicts the least
+// recently used one when it is full.
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func NewLRU(size int, ttl time.Duration) *LRU {
+	return &LRU{
+		size:    max(size, 1),
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (s *LRU) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		s.remove(element)
+		return nil, ErrNotFound
+	}
+	s.order.MoveToFront(element)
+	return entry.value, nil
+}
+
+func (s *LRU) Set(_ context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := time.Now().Add(s.ttl)
+	if element, ok := s.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		s.order.MoveToFront(element)
+		return nil
+	}
+
+	s.entries[key] = s.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	if s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+func (s *LRU) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+	return nil
+}
+
+func (s *LRU) Reset(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.order.Init()
+	s.entries = make(map[string]*list.Element)
+	return nil
+}
+
+func (s *LRU) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*lruEntry).key)
+}
@@ -0,0 +1,41 @@
+package cachestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewLRU(2, time.Minute)
+	ctx := context.Background()
+
+	_ = store.Set(ctx, "a", []byte("1"))
+	_ = store.Set(ctx, "b", []byte("2"))
+	// a becomes the most recently used, so b is evicted by c
+	if _, err := store.Get(ctx, "a"); err != nil {
+		t.Fatalf("Get a: %v", err)
+	}
+	_ = store.Set(ctx, "c", []byte("3"))
+
+	if _, err := store.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get b: err = %v, want ErrNotFound", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := store.Get(ctx, key); err != nil {
+			t.Errorf("Get %s: %v", key, err)
+		}
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	store := NewLRU(10, 10*time.Millisecond)
+	ctx := context.Background()
+
+	_ = store.Set(ctx, "a", []byte("1"))
+	time.Sleep(20 * time.Millisecond)
+	if _, err := store.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of an expired key: err = %v, want ErrNotFound", err)
+	}
+}
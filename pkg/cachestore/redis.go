@@ -0,0 +1,61 @@
+package cachestore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// resetBatch is the number of keys deleted at once by Reset.
+const resetBatch = 500
+
+// Redis keeps the entries in Redis so the replicas share them. The keys are
+// prefixed, Reset drops only the keys of the prefix.
+type Redis struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewRedis(client *redis.Client, prefix string, ttl time.Duration) *Redis {
+	return &Redis{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (s *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *Redis) Set(ctx context.Context, key string, value []byte) error {
+	return s.client.Set(ctx, s.prefix+key, value, s.ttl).Err()
+}
+
+func (s *Redis) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+func (s *Redis) Reset(ctx context.Context) error {
+	iter := s.client.Scan(ctx, 0, s.prefix+"*", resetBatch).Iterator()
+	keys := make([]string, 0, resetBatch)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == resetBatch {
+			if err := s.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
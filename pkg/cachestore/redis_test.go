@@ -0,0 +1,86 @@
+package cachestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedis(t *testing.T, prefix string) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewRedis(client, prefix, time.Minute), server
+}
+
+func TestRedisGetSetDelete(t *testing.T) {
+	store, _ := newRedis(t, "flats:")
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, "client:all:1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of a missing key: err = %v, want ErrNotFound", err)
+	}
+
+	if err := store.Set(ctx, "client:all:1", []byte("flats")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	value, err := store.Get(ctx, "client:all:1")
+	if err != nil || string(value) != "flats" {
+		t.Fatalf("Get = %q, %v, want flats", value, err)
+	}
+
+	if err = store.Delete(ctx, "client:all:1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err = store.Delete(ctx, "client:all:1"); err != nil {
+		t.Fatalf("Delete of a missing key: %v", err)
+	}
+	if _, err = store.Get(ctx, "client:all:1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRedisExpiresEntries(t *testing.T) {
+	store, server := newRedis(t, "flats:")
+	ctx := context.Background()
+
+	if err := store.Set(ctx, "client:all:1", []byte("flats")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if ttl := server.TTL("flats:client:all:1"); ttl != time.Minute {
+		t.Fatalf("TTL = %v, want %v", ttl, time.Minute)
+	}
+
+	server.FastForward(time.Minute)
+	if _, err := store.Get(ctx, "client:all:1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of an expired key: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRedisResetKeepsOtherKeys(t *testing.T) {
+	store, server := newRedis(t, "flats:")
+	ctx := context.Background()
+
+	// more keys than a single batch of the reset
+	for i := 0; i < resetBatch+10; i++ {
+		if err := store.Set(ctx, fmt.Sprintf("client:all:%d", i), []byte("flats")); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	if err := server.Set("sessions:1", "session"); err != nil {
+		t.Fatalf("failed to set a foreign key: %v", err)
+	}
+
+	if err := store.Reset(ctx); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if keys := server.Keys(); len(keys) != 1 || keys[0] != "sessions:1" {
+		t.Fatalf("keys after Reset = %v, want only sessions:1", keys)
+	}
+}
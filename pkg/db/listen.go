@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Listener receives the notifications of a channel. Notifications sent while
+// the connection is lost are not delivered, Connected is called on every
+// connection so the listener can catch up.
+type Listener interface {
+	Connected()
+	Disconnected(err error)
+	Notification(payload string)
+}
+
+// Notify sends the payload to the listeners of the channel.
+func (db Database) Notify(ctx context.Context, channel, payload string) error {
+	_, err := db.cluster.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
+
+// Listen passes the notifications of the channel to the listener until ctx
+// is done, a lost connection is re-established after the retry delay.
+func (db Database) Listen(ctx context.Context, channel string, retry time.Duration, listener Listener) error {
+	for {
+		err := db.listen(ctx, channel, listener)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		listener.Disconnected(err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+func (db Database) listen(ctx context.Context, channel string, listener Listener) error {
+	pooled, err := db.cluster.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// the listening connection is never given back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	listener.Connected()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		listener.Notification(notification.Payload)
+	}
+}
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS houses_address_trgm_idx ON houses USING gin (address gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS houses_developer_idx ON houses (developer);
+CREATE INDEX IF NOT EXISTS houses_year_idx ON houses (year);
+CREATE INDEX IF NOT EXISTS houses_created_at_id_idx ON houses (created_at, id);
+CREATE INDEX IF NOT EXISTS houses_update_at_id_idx ON houses (update_at, id);
+
+CREATE INDEX IF NOT EXISTS flats_house_id_approved_idx ON flats (house_id) WHERE status = 'approved';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS flats_house_id_approved_idx;
+DROP INDEX IF EXISTS houses_update_at_id_idx;
+DROP INDEX IF EXISTS houses_created_at_id_idx;
+DROP INDEX IF EXISTS houses_year_idx;
+DROP INDEX IF EXISTS houses_developer_idx;
+DROP INDEX IF EXISTS houses_address_trgm_idx;
+-- +goose StatementEnd
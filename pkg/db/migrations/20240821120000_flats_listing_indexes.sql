@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS flats_house_id_id_idx ON flats (house_id, id);
+CREATE INDEX IF NOT EXISTS flats_house_id_price_id_idx ON flats (house_id, price, id);
+CREATE INDEX IF NOT EXISTS flats_house_id_rooms_id_idx ON flats (house_id, rooms, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS flats_house_id_rooms_id_idx;
+DROP INDEX IF EXISTS flats_house_id_price_id_idx;
+DROP INDEX IF EXISTS flats_house_id_id_idx;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS flats_approved_price_id_idx ON flats (price, id) WHERE status = 'approved';
+CREATE INDEX IF NOT EXISTS flats_approved_rooms_id_idx ON flats (rooms, id) WHERE status = 'approved';
+CREATE INDEX IF NOT EXISTS flats_approved_id_idx ON flats (id) WHERE status = 'approved';
+CREATE INDEX IF NOT EXISTS flats_approved_house_id_price_idx ON flats (house_id, price) WHERE status = 'approved';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS flats_approved_house_id_price_idx;
+DROP INDEX IF EXISTS flats_approved_id_idx;
+DROP INDEX IF EXISTS flats_approved_rooms_id_idx;
+DROP INDEX IF EXISTS flats_approved_price_id_idx;
+-- +goose StatementEnd
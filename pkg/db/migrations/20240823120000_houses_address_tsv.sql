@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE houses
+    ADD COLUMN IF NOT EXISTS address_tsv tsvector
+        GENERATED ALWAYS AS (to_tsvector('russian', address)) STORED;
+
+CREATE INDEX IF NOT EXISTS houses_address_tsv_idx ON houses USING gin (address_tsv);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS houses_address_tsv_idx;
+ALTER TABLE houses DROP COLUMN IF EXISTS address_tsv;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE houses
+    ADD COLUMN IF NOT EXISTS city               TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS street             TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS building           TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS block              TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS address_normalized TEXT;
+
+-- houses created before the structured address have no normalized form
+-- and do not take part in the uniqueness check
+CREATE UNIQUE INDEX IF NOT EXISTS houses_address_normalized_idx ON houses (address_normalized);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS houses_address_normalized_idx;
+ALTER TABLE houses
+    DROP COLUMN IF EXISTS address_normalized,
+    DROP COLUMN IF EXISTS block,
+    DROP COLUMN IF EXISTS building,
+    DROP COLUMN IF EXISTS street,
+    DROP COLUMN IF EXISTS city;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE houses
+    ADD COLUMN IF NOT EXISTS latitude  DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS geohash   TEXT;
+
+-- geohash is computed by the service, prefix search needs text_pattern_ops
+CREATE INDEX IF NOT EXISTS houses_geohash_idx ON houses (geohash text_pattern_ops) WHERE geohash IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS houses_geohash_idx;
+ALTER TABLE houses
+    DROP COLUMN IF EXISTS geohash,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose NO TRANSACTION
+-- The flats table is large: the columns are added without a rewrite
+-- (nullable or with a constant default) and the index is built concurrently.
+
+-- +goose Up
+ALTER TABLE flats
+    ADD COLUMN IF NOT EXISTS number      INT,
+    ADD COLUMN IF NOT EXISTS area        NUMERIC(8, 2),
+    ADD COLUMN IF NOT EXISTS floor       INT,
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS flats_house_id_number_idx
+    ON flats (house_id, number) WHERE number IS NOT NULL;
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS flats_house_id_number_idx;
+
+ALTER TABLE flats
+    DROP COLUMN IF EXISTS description,
+    DROP COLUMN IF EXISTS floor,
+    DROP COLUMN IF EXISTS area,
+    DROP COLUMN IF EXISTS number;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS author_id UUID;
+
+CREATE TABLE IF NOT EXISTS flat_photos
+(
+    id            SERIAL PRIMARY KEY,
+    flat_id       INT          NOT NULL,
+    key           TEXT         NOT NULL,
+    thumbnail_key TEXT         NOT NULL,
+    url           TEXT         NOT NULL,
+    thumbnail_url TEXT         NOT NULL,
+    content_type  VARCHAR(100) NOT NULL,
+    size          BIGINT       NOT NULL,
+    created_at    TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS flat_photos_flat_id_idx ON flat_photos (flat_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS flat_photos;
+ALTER TABLE flats DROP COLUMN IF EXISTS author_id;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TABLE flats
+    ADD COLUMN IF NOT EXISTS moderation_reason TEXT    NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS priority          BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS flats_house_id_priority_idx ON flats (house_id, id) WHERE priority;
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS flats_house_id_priority_idx;
+
+ALTER TABLE flats
+    DROP COLUMN IF EXISTS priority,
+    DROP COLUMN IF EXISTS moderation_reason;
@@ -0,0 +1,12 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS duplicate_of INT;
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS flats_duplicate_of_idx ON flats (duplicate_of) WHERE duplicate_of IS NOT NULL;
+CREATE INDEX CONCURRENTLY IF NOT EXISTS flats_house_id_author_id_idx ON flats (house_id, author_id) WHERE author_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS flats_house_id_author_id_idx;
+DROP INDEX CONCURRENTLY IF EXISTS flats_duplicate_of_idx;
+
+ALTER TABLE flats DROP COLUMN IF EXISTS duplicate_of;
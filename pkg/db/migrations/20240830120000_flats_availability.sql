@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a constant default does not rewrite the flats table
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS availability VARCHAR(20) NOT NULL DEFAULT 'active';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE flats DROP COLUMN IF EXISTS availability;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS price_dropped BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS flat_price_history
+(
+    id         SERIAL PRIMARY KEY,
+    flat_id    INT NOT NULL,
+    old_price  INT NOT NULL,
+    new_price  INT NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS flat_price_history_flat_id_idx ON flat_price_history (flat_id, changed_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS flat_price_history;
+ALTER TABLE flats DROP COLUMN IF EXISTS price_dropped;
+-- +goose StatementEnd
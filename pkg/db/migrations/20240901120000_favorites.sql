@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS favorites
+(
+    user_id    UUID NOT NULL,
+    flat_id    INT  NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    PRIMARY KEY (user_id, flat_id)
+);
+
+CREATE INDEX IF NOT EXISTS favorites_flat_id_idx ON favorites (flat_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS favorites;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS saved_searches
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    price_from INT  NOT NULL DEFAULT 0,
+    price_to   INT  NOT NULL DEFAULT 0,
+    rooms_from INT  NOT NULL DEFAULT 0,
+    rooms_to   INT  NOT NULL DEFAULT 0,
+    developer  TEXT NOT NULL DEFAULT '',
+    address    TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS saved_searches_user_id_idx ON saved_searches (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS saved_searches;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS delivery VARCHAR(20) NOT NULL DEFAULT 'instant';
+
+CREATE TABLE IF NOT EXISTS pending_notifications
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    message    TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS pending_notifications_user_id_idx ON pending_notifications (user_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS pending_notifications;
+ALTER TABLE users DROP COLUMN IF EXISTS delivery;
+-- +goose StatementEnd
@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         SERIAL PRIMARY KEY,
+    owner_id   UUID   NOT NULL,
+    url        TEXT   NOT NULL,
+    events     TEXT[] NOT NULL,
+    secret     TEXT   NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_owner_id_idx ON webhooks (owner_id);
+CREATE INDEX IF NOT EXISTS webhooks_events_idx ON webhooks USING GIN (events);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              SERIAL PRIMARY KEY,
+    webhook_id      INT          NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event           VARCHAR(100) NOT NULL,
+    payload         TEXT         NOT NULL,
+    status          VARCHAR(20)  NOT NULL DEFAULT 'pending',
+    attempts        INT          NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    response_code   INT,
+    last_error      TEXT         NOT NULL DEFAULT '',
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+    WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS moderator_id UUID;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE flats DROP COLUMN IF EXISTS moderator_id;
+-- +goose StatementEnd
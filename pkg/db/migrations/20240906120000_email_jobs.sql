@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS email_jobs
+(
+    id              SERIAL PRIMARY KEY,
+    idempotency_key TEXT        NOT NULL UNIQUE,
+    recipient       TEXT        NOT NULL,
+    message         TEXT        NOT NULL,
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    last_error      TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS email_jobs_due_idx ON email_jobs (next_attempt_at) WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS email_jobs;
+-- +goose StatementEnd
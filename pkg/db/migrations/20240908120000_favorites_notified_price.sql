@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- notified_price is the price the user last saw, a price change is sent
+-- once the edited flat is approved again
+ALTER TABLE favorites ADD COLUMN IF NOT EXISTS notified_price INT;
+
+UPDATE favorites SET notified_price = flats.price FROM flats WHERE flats.id = favorites.flat_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE favorites DROP COLUMN IF EXISTS notified_price;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- approved flats waiting for the next batch of saved search alerts
+CREATE TABLE IF NOT EXISTS search_alert_queue
+(
+    flat_id       INT PRIMARY KEY,
+    claimed_until TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    created_at    TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS search_alert_queue;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the claim of a job, an attempt records its outcome only under its own claim
+ALTER TABLE email_jobs ADD COLUMN IF NOT EXISTS lease_token UUID;
+CREATE INDEX IF NOT EXISTS email_jobs_sent_idx ON email_jobs (updated_at) WHERE status = 'sent';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS email_jobs_sent_idx;
+ALTER TABLE email_jobs DROP COLUMN IF EXISTS lease_token;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the normalized address starts with the city, the same street in two
+-- cities are different houses
+UPDATE houses SET address_normalized = city || '|' || address_normalized WHERE address_normalized IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- the houses of different cities may collide without the city, the later
+-- ones leave the uniqueness check like the houses without the normalized form
+DROP INDEX IF EXISTS houses_address_normalized_idx;
+UPDATE houses SET address_normalized = substr(address_normalized, strpos(address_normalized, '|') + 1)
+WHERE address_normalized IS NOT NULL;
+UPDATE houses h SET address_normalized = NULL
+WHERE EXISTS (SELECT 1 FROM houses o WHERE o.address_normalized = h.address_normalized AND o.id < h.id);
+CREATE UNIQUE INDEX IF NOT EXISTS houses_address_normalized_idx ON houses (address_normalized);
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- approved_price is the price the clients last saw, the price change of an
+-- edited flat is announced once it is approved again
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS approved_price INT;
+
+UPDATE flats SET approved_price = price WHERE status = 'approved';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE flats DROP COLUMN IF EXISTS approved_price;
+-- +goose StatementEnd
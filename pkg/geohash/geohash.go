@@ -0,0 +1,128 @@
+package geohash
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	base32       = "0123456789bcdefghjkmnpqrstuvwxyz"
+	MaxPrecision = 12
+
+	earthRadius     = 6371000.0
+	metersPerDegree = math.Pi * earthRadius / 180
+)
+
+type Box struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+func (b Box) Valid() bool {
+	return b.MinLat <= b.MaxLat && b.MinLon <= b.MaxLon &&
+		b.MinLat >= -90 && b.MaxLat <= 90 && b.MinLon >= -180 && b.MaxLon <= 180
+}
+
+// Encode returns the geohash of the point with the given number of characters.
+func Encode(lat, lon float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	var hash strings.Builder
+	even := true
+	bit, ch := 0, 0
+	for hash.Len() < precision {
+		if even {
+			ch = ch<<1 | bisect(&lonRange, lon)
+		} else {
+			ch = ch<<1 | bisect(&latRange, lat)
+		}
+		even = !even
+
+		if bit++; bit == 5 {
+			hash.WriteByte(base32[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return hash.String()
+}
+
+func bisect(r *[2]float64, value float64) int {
+	mid := (r[0] + r[1]) / 2
+	if value >= mid {
+		r[0] = mid
+		return 1
+	}
+	r[1] = mid
+	return 0
+}
+
+// CellSize returns the height and width in degrees of a cell of the precision.
+func CellSize(precision int) (float64, float64) {
+	bits := 5 * precision
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Exp2(float64(latBits)), 360 / math.Exp2(float64(lonBits))
+}
+
+// Cover returns geohash cells covering the box, using the finest precision
+// which needs no more than maxCells cells. Invalid boxes, including the ones
+// crossing the antimeridian with MinLon > MaxLon, are not covered.
+func Cover(box Box, maxCells int) []string {
+	if !box.Valid() {
+		return nil
+	}
+
+	precision := 1
+	for p := MaxPrecision; p >= 1; p-- {
+		if cellCount(box, p) <= maxCells {
+			precision = p
+			break
+		}
+	}
+
+	height, width := CellSize(precision)
+	seen := make(map[string]bool)
+	var cells []string
+	for lat := cellCenter(box.MinLat, -90, height); lat-height/2 <= box.MaxLat && lat < 90; lat += height {
+		for lon := cellCenter(box.MinLon, -180, width); lon-width/2 <= box.MaxLon && lon < 180; lon += width {
+			hash := Encode(lat, lon, precision)
+			if !seen[hash] {
+				seen[hash] = true
+				cells = append(cells, hash)
+			}
+		}
+	}
+	return cells
+}
+
+func cellCount(box Box, precision int) int {
+	height, width := CellSize(precision)
+	rows := math.Floor((box.MaxLat+90)/height) - math.Floor((box.MinLat+90)/height) + 1
+	cols := math.Floor((box.MaxLon+180)/width) - math.Floor((box.MinLon+180)/width) + 1
+	return int(rows * cols)
+}
+
+// cellCenter returns the center of the cell containing value on a grid of
+// the given step starting at origin.
+func cellCenter(value, origin, step float64) float64 {
+	return origin + math.Floor((value-origin)/step)*step + step/2
+}
+
+// RadiusBox returns the box around the point containing the circle of the
+// radius in meters.
+func RadiusBox(lat, lon, radius float64) Box {
+	dLat := radius / metersPerDegree
+	dLon := 180.0
+	if cos := math.Cos(lat * math.Pi / 180); cos > 1e-9 {
+		dLon = math.Min(dLat/cos, 180)
+	}
+	return Box{
+		MinLat: math.Max(lat-dLat, -90),
+		MinLon: math.Max(lon-dLon, -180),
+		MaxLat: math.Min(lat+dLat, 90),
+		MaxLon: math.Min(lon+dLon, 180),
+	}
+}
@@ -0,0 +1,134 @@
+package geohash
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		lat, lon  float64
+		precision int
+		want      string
+	}{
+		{lat: 57.64911, lon: 10.40744, precision: 11, want: "u4pruydqqvj"},
+		{lat: 42.605, lon: -5.603, precision: 5, want: "ezs42"},
+		{lat: 0, lon: 0, precision: 1, want: "s"},
+		{lat: -90, lon: -180, precision: 3, want: "000"},
+		{lat: 90, lon: 180, precision: 3, want: "zzz"},
+	}
+	for _, tt := range tests {
+		if got := Encode(tt.lat, tt.lon, tt.precision); got != tt.want {
+			t.Errorf("Encode(%v, %v, %d) = %q, want %q", tt.lat, tt.lon, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestCover(t *testing.T) {
+	tests := []struct {
+		name     string
+		box      Box
+		maxCells int
+		want     []string
+	}{
+		{
+			name:     "box inside one cell",
+			box:      Box{MinLat: 57.6491, MinLon: 10.4074, MaxLat: 57.6492, MaxLon: 10.4075},
+			maxCells: 1,
+			want:     []string{"u4pruyd"},
+		},
+		{
+			name:     "whole world",
+			box:      Box{MinLat: -90, MinLon: -180, MaxLat: 90, MaxLon: 180},
+			maxCells: 32,
+			want:     strings.Split(base32, ""),
+		},
+		{
+			name:     "box across the equator and the meridian",
+			box:      Box{MinLat: -1, MinLon: -1, MaxLat: 1, MaxLon: 1},
+			maxCells: 4,
+			want:     []string{"7zz", "ebp", "kpb", "s00"},
+		},
+		{
+			name:     "box crossing the antimeridian",
+			box:      Box{MinLat: 0, MinLon: 179, MaxLat: 1, MaxLon: -179},
+			maxCells: 10,
+		},
+		{
+			name:     "latitude out of range",
+			box:      Box{MinLat: -91, MinLon: 0, MaxLat: 0, MaxLon: 1},
+			maxCells: 10,
+		},
+	}
+	for _, tt := range tests {
+		got := Cover(tt.box, tt.maxCells)
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: Cover = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCoverContainsCorners(t *testing.T) {
+	box := Box{MinLat: 55.70, MinLon: 37.50, MaxLat: 55.80, MaxLon: 37.70}
+	for _, maxCells := range []int{1, 4, 16, 64} {
+		cells := Cover(box, maxCells)
+		if len(cells) == 0 || len(cells) > maxCells && len(cells[0]) > 1 {
+			t.Fatalf("Cover(%d) returned %d cells", maxCells, len(cells))
+		}
+		for _, corner := range [][2]float64{
+			{box.MinLat, box.MinLon}, {box.MinLat, box.MaxLon}, {box.MaxLat, box.MinLon}, {box.MaxLat, box.MaxLon},
+		} {
+			hash := Encode(corner[0], corner[1], MaxPrecision)
+			covered := false
+			for _, cell := range cells {
+				covered = covered || strings.HasPrefix(hash, cell)
+			}
+			if !covered {
+				t.Errorf("Cover(%d) = %q does not contain corner %v", maxCells, cells, corner)
+			}
+		}
+	}
+}
+
+func TestRadiusBox(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		radius   float64
+		want     Box
+	}{
+		{
+			name: "equator", lat: 0, lon: 0, radius: metersPerDegree,
+			want: Box{MinLat: -1, MinLon: -1, MaxLat: 1, MaxLon: 1},
+		},
+		{
+			name: "longitude degrees are shorter to the north", lat: 60, lon: 30, radius: metersPerDegree,
+			want: Box{MinLat: 59, MinLon: 28, MaxLat: 61, MaxLon: 32},
+		},
+		{
+			name: "pole", lat: 90, lon: 0, radius: metersPerDegree,
+			want: Box{MinLat: 89, MinLon: -180, MaxLat: 90, MaxLon: 180},
+		},
+		{
+			name: "clamped at the antimeridian", lat: 0, lon: 179.5, radius: metersPerDegree,
+			want: Box{MinLat: -1, MinLon: 178.5, MaxLat: 1, MaxLon: 180},
+		},
+	}
+	for _, tt := range tests {
+		got := RadiusBox(tt.lat, tt.lon, tt.radius)
+		if !closeBox(got, tt.want) {
+			t.Errorf("%s: RadiusBox = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if !got.Valid() {
+			t.Errorf("%s: RadiusBox = %+v is not valid", tt.name, got)
+		}
+	}
+}
+
+func closeBox(a, b Box) bool {
+	const eps = 1e-9
+	near := func(x, y float64) bool { return x-y < eps && y-x < eps }
+	return near(a.MinLat, b.MinLat) && near(a.MinLon, b.MinLon) && near(a.MaxLat, b.MaxLat) && near(a.MaxLon, b.MaxLon)
+}
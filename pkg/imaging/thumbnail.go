@@ -0,0 +1,55 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// Thumbnail scales the image down so that its longest side is at most
+// maxSize pixels, every target pixel is the average of the source box it
+// covers. Images which are already small enough are returned as is.
+func Thumbnail(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return src
+	}
+
+	dstWidth, dstHeight := maxSize, height*maxSize/width
+	if height > width {
+		dstWidth, dstHeight = width*maxSize/height, maxSize
+	}
+	dstWidth, dstHeight = max(dstWidth, 1), max(dstHeight, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := max(bounds.Min.Y+(y+1)*height/dstHeight, y0+1)
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := max(bounds.Min.X+(x+1)*width/dstWidth, x0+1)
+			dst.SetRGBA(x, y, average(src, x0, y0, x1, y1))
+		}
+	}
+	return dst
+}
+
+func average(src image.Image, x0, y0, x1, y1 int) color.RGBA {
+	var r, g, b, a, n uint64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			cr, cg, cb, ca := src.At(x, y).RGBA()
+			r += uint64(cr)
+			g += uint64(cg)
+			b += uint64(cb)
+			a += uint64(ca)
+			n++
+		}
+	}
+	return color.RGBA{
+		R: uint8(r / n >> 8),
+		G: uint8(g / n >> 8),
+		B: uint8(b / n >> 8),
+		A: uint8(a / n >> 8),
+	}
+}
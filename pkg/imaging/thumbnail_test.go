@@ -0,0 +1,54 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestThumbnailSize(t *testing.T) {
+	tests := []struct {
+		width, height int
+		maxSize       int
+		wantW, wantH  int
+	}{
+		{width: 1000, height: 500, maxSize: 320, wantW: 320, wantH: 160},
+		{width: 500, height: 1000, maxSize: 320, wantW: 160, wantH: 320},
+		{width: 640, height: 640, maxSize: 320, wantW: 320, wantH: 320},
+		{width: 5000, height: 2, maxSize: 320, wantW: 320, wantH: 1},
+		{width: 200, height: 100, maxSize: 320, wantW: 200, wantH: 100},
+	}
+	for _, tt := range tests {
+		src := image.NewRGBA(image.Rect(0, 0, tt.width, tt.height))
+		bounds := Thumbnail(src, tt.maxSize).Bounds()
+		if bounds.Dx() != tt.wantW || bounds.Dy() != tt.wantH {
+			t.Errorf(
+				"Thumbnail(%dx%d, %d) = %dx%d, want %dx%d",
+				tt.width, tt.height, tt.maxSize, bounds.Dx(), bounds.Dy(), tt.wantW, tt.wantH,
+			)
+		}
+	}
+}
+
+func TestThumbnailAveragesPixels(t *testing.T) {
+	// vertical stripes of black and white average to grey
+	src := image.NewRGBA(image.Rect(10, 10, 14, 12))
+	for x := 10; x < 14; x++ {
+		for y := 10; y < 12; y++ {
+			c := color.RGBA{A: 255}
+			if x%2 == 0 {
+				c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+
+	dst := Thumbnail(src, 2)
+	if dst.Bounds() != image.Rect(0, 0, 2, 1) {
+		t.Fatalf("bounds = %v", dst.Bounds())
+	}
+	r, g, b, a := dst.At(0, 0).RGBA()
+	if r>>8 != 127 || g>>8 != 127 || b>>8 != 127 || a>>8 != 255 {
+		t.Errorf("pixel = %d %d %d %d, want grey", r>>8, g>>8, b>>8, a>>8)
+	}
+}